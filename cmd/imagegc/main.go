@@ -0,0 +1,55 @@
+// Command imagegc removes stored image files that no product image refers
+// to, such as leftovers of failed uploads or of deletions whose file cleanup
+// failed, and the images of deleted products. It uses the same .env as the
+// API.
+//
+//	go run ./cmd/imagegc -dry-run      # report only
+//	go run ./cmd/imagegc -min-age 24h  # delete orphans older than a day
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"bogbon-api/config"
+	"bogbon-api/repository"
+	"bogbon-api/storage"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "report orphaned files without deleting anything")
+	minAge := flag.Duration("min-age", time.Hour, "spare unreferenced files younger than this, which may belong to uploads in progress")
+	verbose := flag.Bool("v", false, "list every orphaned file")
+	flag.Parse()
+
+	config.InitDB()
+	files, err := storage.FromEnv()
+	if err != nil {
+		log.Fatal("Failed to configure storage:", err)
+	}
+	storage.Files = files
+
+	// images saved before storage keys existed are only known by URL
+	if err := repository.MigrateImageKeys(); err != nil {
+		log.Fatal("Failed to migrate image keys:", err)
+	}
+
+	report, err := repository.CollectImageGarbage(*minAge, *dryRun)
+	if err != nil {
+		log.Fatal("Image garbage collection failed:", err)
+	}
+
+	verb := "Deleted"
+	if *dryRun {
+		verb = "Would delete"
+	}
+	if *verbose || *dryRun {
+		for _, obj := range report.Orphans {
+			fmt.Printf("%s\t%d\t%s\n", obj.Key, obj.Size, obj.ModTime.Format(time.RFC3339))
+		}
+	}
+	fmt.Printf("Scanned %d file(s). %s %d orphaned file(s), %d byte(s), and %d image(s) of deleted products. Skipped %d recent file(s).\n",
+		report.Scanned, verb, len(report.Orphans), report.OrphanBytes, report.DeletedImages, report.SkippedRecents)
+}
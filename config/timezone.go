@@ -0,0 +1,6 @@
+package config
+
+import "time"
+
+// Location is the business time zone used for schedules and booking slots (UTC+5, no DST).
+var Location = time.FixedZone("Asia/Tashkent", 5*60*60)
@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"bogbon-api/models"
+	"bogbon-api/repository"
+	"bogbon-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const adminTokenTTL = 12 * time.Hour
+
+// AdminLogin exchanges admin credentials for a signed bearer token.
+func AdminLogin(c *gin.Context) {
+	var input struct {
+		Username string `json:"username" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	admin, err := repository.AuthenticateAdmin(input.Username, input.Password)
+	if errors.Is(err, repository.ErrInvalidCredentials) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, err := utils.GenerateToken(admin.ID, admin.Role, adminTokenTTL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not issue token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":      token,
+		"expires_in": int(adminTokenTTL.Seconds()),
+		"admin":      admin,
+	})
+}
+
+// ListAdmins returns all admin accounts (owner only).
+func ListAdmins(c *gin.Context) {
+	admins, err := repository.GetAllAdmins()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, admins)
+}
+
+// CreateAdmin adds a new admin account with the given role (owner only).
+func CreateAdmin(c *gin.Context) {
+	var input struct {
+		Username string `json:"username" binding:"required"`
+		Password string `json:"password" binding:"required,min=8"`
+		Role     string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	switch input.Role {
+	case models.RoleOwner, models.RoleCatalogEditor, models.RoleOrderManager:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid role"})
+		return
+	}
+
+	admin, err := repository.CreateAdmin(input.Username, input.Password, input.Role)
+	if errors.Is(err, repository.ErrUsernameTaken) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, admin)
+}
+
+// DeleteAdmin removes an admin account by ID (owner only).
+func DeleteAdmin(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ID"})
+		return
+	}
+	if uint(id) == c.GetUint("admin_id") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot delete your own account"})
+		return
+	}
+	if err := repository.DeleteAdmin(uint(id)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"bogbon-api/models"
+	"bogbon-api/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+type labelInput struct {
+	LanguageCode string `json:"language_code" binding:"required,max=10"`
+	Label        string `json:"label" binding:"required"`
+}
+
+type attributeInput struct {
+	Code         string       `json:"code" binding:"required,max=50"`
+	Type         string       `json:"type"` // required on create, fixed afterwards
+	Unit         string       `json:"unit" binding:"max=20"`
+	Position     int          `json:"position"`
+	Translations []labelInput `json:"translations" binding:"dive"`
+	Values       []struct {
+		Code         string       `json:"code" binding:"required,max=50"`
+		Position     int          `json:"position"`
+		Translations []labelInput `json:"translations" binding:"dive"`
+	} `json:"values" binding:"dive"`
+}
+
+func (in attributeInput) model() models.AttributeDefinition {
+	a := models.AttributeDefinition{Code: in.Code, Type: in.Type, Unit: in.Unit, Position: in.Position}
+	for _, t := range in.Translations {
+		a.Translations = append(a.Translations, models.AttributeTranslation{LanguageCode: t.LanguageCode, Label: t.Label})
+	}
+	for _, v := range in.Values {
+		value := models.AttributeValue{Code: v.Code, Position: v.Position}
+		for _, t := range v.Translations {
+			value.Translations = append(value.Translations, models.AttributeValueTranslation{LanguageCode: t.LanguageCode, Label: t.Label})
+		}
+		a.Values = append(a.Values, value)
+	}
+	return a
+}
+
+// ListAttributes returns every attribute definition with its labels and enum
+// values, e.g. to build the specification filters of the catalog.
+func ListAttributes(c *gin.Context) {
+	attrs, err := repository.GetAllAttributes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, attrs)
+}
+
+// CreateAttribute defines a new attribute such as light requirement or mature height.
+func CreateAttribute(c *gin.Context) {
+	var input attributeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	attr := input.model()
+	if err := repository.CreateAttribute(&attr); err != nil {
+		respondAttributeError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, attr)
+}
+
+// UpdateAttribute replaces an attribute's code, unit, position, labels and
+// enum values. Its type cannot change.
+func UpdateAttribute(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid attribute ID"})
+		return
+	}
+	var input attributeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	attr := input.model()
+	attr.ID = uint(id)
+	if err := repository.UpdateAttribute(&attr); err != nil {
+		respondAttributeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, attr)
+}
+
+// DeleteAttribute removes an attribute and its values from every product.
+func DeleteAttribute(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid attribute ID"})
+		return
+	}
+	if err := repository.DeleteAttribute(uint(id)); err != nil {
+		respondAttributeError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// SetProductAttributes replaces the specifications of a product, e.g.
+// [{"code":"light","value":["bright_indirect"]},{"code":"height_cm","value":120}].
+func SetProductAttributes(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+	var input []repository.ProductAttributeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	attrs, err := repository.SetProductAttributes(uint(id), input)
+	if err != nil {
+		respondAttributeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, attrs)
+}
+
+func respondAttributeError(c *gin.Context, err error) {
+	var valueErr *repository.AttributeValueError
+	switch {
+	case errors.As(err, &valueErr), errors.Is(err, repository.ErrAttributeType):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, repository.ErrAttributeNotFound), errors.Is(err, repository.ErrProductNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, repository.ErrAttributeCodeTaken), errors.Is(err, repository.ErrAttributeTypeChange):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -3,6 +3,7 @@ package controllers
 import (
 	"net/http"
 	"strconv"
+	"time"
 
 	"bogbon-api/models"
 	"bogbon-api/repository"
@@ -20,18 +21,34 @@ import (
 // @Param input body requests.AddToCartInput true "Product to add"
 // @Success      201    {object}  models.CartItem
 // @Failure      400    {object}  map[string]string
+// @Failure      409    {object}  map[string]string
 // @Failure      500    {object}  map[string]string
 // @Router       /cart [post]
 func AddToCart(c *gin.Context) {
 	var input struct {
-		ProductID uint `json:"product_id" binding:"required"`
-		Quantity  int  `json:"quantity" binding:"gte=1"`
+		ProductID uint       `json:"product_id" binding:"required"`
+		VariantID *uint      `json:"variant_id"`
+		Quantity  int        `json:"quantity" binding:"gte=1"`
+		SlotStart *time.Time `json:"slot_start"`
 	}
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	if err := repository.CheckVariant(input.ProductID, input.VariantID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// A slot picked now is only checked; it is reserved at checkout
+	if input.SlotStart != nil {
+		if err := repository.CheckSlot(input.ProductID, *input.SlotStart, input.Quantity); err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
 	sessionID := utils.GetSessionID(c)
 	cart, err := repository.EnsureCart(sessionID)
 	if err != nil {
@@ -42,7 +59,9 @@ func AddToCart(c *gin.Context) {
 	item := models.CartItem{
 		CartID:    cart.ID,
 		ProductID: input.ProductID,
+		VariantID: input.VariantID,
 		Quantity:  input.Quantity,
+		SlotStart: input.SlotStart,
 	}
 	if err := repository.AddCartItem(&item); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -67,7 +86,7 @@ func GetCart(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, cart.Items)
+	c.JSON(http.StatusOK, localizeCartItems(c, cart.Items))
 }
 
 // UpdateCartItem godoc
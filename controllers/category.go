@@ -3,52 +3,77 @@ package controllers
 import (
 	"bogbon-api/models"
 	"bogbon-api/repository"
+	"bogbon-api/responses"
+	"bogbon-api/utils"
+	"errors"
 	"net/http"
-	"sort"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
 
-// ListCategories responds with all categories (including translations),
-// optionally filtered by translation name using ?q=
+// ListCategories responds with a page of categories (including translations),
+// optionally filtered by translation name using ?q= and sorted by name in the negotiated language (?lang= or Accept-Language)
 func ListCategories(c *gin.Context) {
-	q := c.Query("q")
-	var cats []models.Category
-	var err error
+	p, ok := pageParams(c)
+	if !ok {
+		return
+	}
 
-	if q != "" {
-		// Filter by translated name
-		cats, err = repository.FilterCategories(repository.CategoryFilter{Q: q})
-	} else {
-		// Return all categories
-		cats, err = repository.GetAllCategories()
+	f := repository.CategoryFilter{Q: c.Query("q"), Lang: utils.Language(c)}
+	cats, meta, err := repository.FilterCategories(f, p)
+	if err != nil {
+		respondListError(c, err)
+		return
 	}
 
+	c.JSON(http.StatusOK, gin.H{"data": localizeCategories(c, cats), "meta": meta})
+}
+
+// CategoryTree responds with the whole category tree, localized to the
+// negotiated language, e.g. Indoor plants > Succulents > Cacti.
+func CategoryTree(c *gin.Context) {
+	tree, err := repository.GetCategoryTree()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	langs := utils.Languages(c)
+	c.Header("Content-Language", langs[0])
+	c.JSON(http.StatusOK, responses.NewCategories(tree, langs))
+}
 
-	// Sort by length of the first translation name (desc)
-	sort.SliceStable(cats, func(i, j int) bool {
-		nameI := ""
-		nameJ := ""
-		if len(cats[i].Translations) > 0 {
-			nameI = cats[i].Translations[0].Name
-		}
-		if len(cats[j].Translations) > 0 {
-			nameJ = cats[j].Translations[0].Name
-		}
-		return len(nameI) > len(nameJ)
-	})
+// GetCategoryBySlug looks a category up by its slug in one language.
+// Former slugs answer with a 301 to the current one.
+func GetCategoryBySlug(c *gin.Context) {
+	lang, slug := c.Param("lang"), c.Param("slug")
+	id, current, redirected, err := repository.ResolveSlug(models.SlugCategory, lang, slug)
+	if err != nil {
+		respondSlugError(c, err)
+		return
+	}
+	if redirected && current != "" {
+		redirectToSlug(c, "/api/categories/by-slug/", lang, current)
+		return
+	}
 
-	c.JSON(http.StatusOK, cats)
+	category, err := repository.GetCategoryByID(id)
+	if err != nil {
+		respondCategoryError(c, err)
+		return
+	}
+	if langs, ok := flatLanguages(c); ok {
+		c.JSON(http.StatusOK, responses.NewCategory(*category, langs))
+		return
+	}
+	c.JSON(http.StatusOK, category)
 }
 
 // CreateCategory adds a new category with translations.
 func CreateCategory(c *gin.Context) {
 	var input struct {
+		ParentID     *uint `json:"parent_id"`
+		Position     int   `json:"position"`
 		Translations map[string]struct {
 			Name string `json:"name"`
 		} `json:"translations"`
@@ -65,11 +90,11 @@ func CreateCategory(c *gin.Context) {
 		return
 	}
 
-	category := models.Category{}
+	category := models.Category{ParentID: input.ParentID, Position: input.Position}
 
 	createdCategory, err := repository.CreateCategory(&category, input.Translations)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondCategoryError(c, err)
 		return
 	}
 
@@ -85,7 +110,7 @@ func DeleteCategory(c *gin.Context) {
 		return
 	}
 	if err := repository.DeleteCategory(uint(id)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondCategoryError(c, err)
 		return
 	}
 	c.Status(http.StatusNoContent)
@@ -100,7 +125,11 @@ func UpdateCategory(c *gin.Context) {
 		return
 	}
 
+	// parent_id and position are optional: leave them out to keep the
+	// category where it is, or send parent_id 0 to move it to the top level.
 	var input struct {
+		ParentID     *uint `json:"parent_id"`
+		Position     *int  `json:"position"`
 		Translations map[string]struct {
 			Name string `json:"name"`
 		} `json:"translations"`
@@ -119,11 +148,33 @@ func UpdateCategory(c *gin.Context) {
 	}
 
 	updatedCategory := models.Category{ID: uint(id)}
+	var columns []string
+	if input.ParentID != nil {
+		columns = append(columns, "parent_id")
+		if *input.ParentID != 0 {
+			updatedCategory.ParentID = input.ParentID
+		}
+	}
+	if input.Position != nil {
+		columns = append(columns, "position")
+		updatedCategory.Position = *input.Position
+	}
 
-	if err := repository.UpdateCategory(&updatedCategory, input.Translations); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := repository.UpdateCategory(&updatedCategory, columns, input.Translations); err != nil {
+		respondCategoryError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, updatedCategory)
 }
+
+func respondCategoryError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, repository.ErrCategoryNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, repository.ErrParentNotFound), errors.Is(err, repository.ErrCategoryCycle):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
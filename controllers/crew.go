@@ -0,0 +1,286 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"bogbon-api/config"
+	"bogbon-api/models"
+	"bogbon-api/repository"
+	"bogbon-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListCrews returns all crews with their workers.
+func ListCrews(c *gin.Context) {
+	crews, err := repository.GetAllCrews()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, crews)
+}
+
+// CreateCrew adds a new crew.
+func CreateCrew(c *gin.Context) {
+	var input struct {
+		Name string `json:"name" binding:"required,max=100"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	crew := models.Crew{Name: input.Name, Active: true}
+	if err := repository.CreateCrew(&crew); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, crew)
+}
+
+// UpdateCrew renames or (de)activates a crew.
+func UpdateCrew(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid crew ID"})
+		return
+	}
+
+	// active is optional: leave it out to keep the crew's current state
+	var input struct {
+		Name   string `json:"name" binding:"required,max=100"`
+		Active *bool  `json:"active"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	crew := models.Crew{ID: uint(id), Name: input.Name}
+	columns := []string{"name"}
+	if input.Active != nil {
+		crew.Active = *input.Active
+		columns = append(columns, "active")
+	}
+	err = repository.UpdateCrew(&crew, columns)
+	if errors.Is(err, repository.ErrCrewNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, crew)
+}
+
+// DeleteCrew removes a crew by ID.
+func DeleteCrew(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid crew ID"})
+		return
+	}
+	if err := repository.DeleteCrew(uint(id)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+type workerInput struct {
+	CrewID *uint  `json:"crew_id"`
+	Name   string `json:"name" binding:"required,max=100"`
+	Phone  string `json:"phone"`
+	Active *bool  `json:"active"`
+}
+
+func (in workerInput) toModel() (models.Worker, bool) {
+	w := models.Worker{CrewID: in.CrewID, Name: in.Name, Active: true}
+	if in.Active != nil {
+		w.Active = *in.Active
+	}
+	if in.Phone != "" {
+		phone, ok := utils.NormalizePhone(in.Phone)
+		if !ok {
+			return w, false
+		}
+		w.Phone = phone
+	}
+	return w, true
+}
+
+// CreateWorker adds a worker, optionally to a crew.
+func CreateWorker(c *gin.Context) {
+	var input workerInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	worker, ok := input.toModel()
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid phone number"})
+		return
+	}
+
+	if err := repository.CreateWorker(&worker); err != nil {
+		respondWorkerError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, worker)
+}
+
+// UpdateWorker changes a worker's details or moves them to another crew.
+func UpdateWorker(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid worker ID"})
+		return
+	}
+
+	var input workerInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	worker, ok := input.toModel()
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid phone number"})
+		return
+	}
+	worker.ID = uint(id)
+
+	if err := repository.UpdateWorker(&worker); err != nil {
+		respondWorkerError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, worker)
+}
+
+func respondWorkerError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, repository.ErrWorkerNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, repository.ErrCrewNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// DeleteWorker removes a worker by ID.
+func DeleteWorker(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid worker ID"})
+		return
+	}
+	if err := repository.DeleteWorker(uint(id)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// AssignOrder dispatches an order's service items to a crew on a date.
+// start_time/end_time ("HH:MM") set the window; without them each item's booked slot is used.
+func AssignOrder(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid order ID"})
+		return
+	}
+
+	var input struct {
+		CrewID    uint   `json:"crew_id" binding:"required"`
+		Date      string `json:"date"`
+		StartTime string `json:"start_time"`
+		EndTime   string `json:"end_time"`
+		ItemIDs   []uint `json:"item_ids"`
+		Note      string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var start, end *time.Time
+	if input.StartTime != "" || input.EndTime != "" {
+		s, errS := time.ParseInLocation("2006-01-02 15:04", input.Date+" "+input.StartTime, config.Location)
+		e, errE := time.ParseInLocation("2006-01-02 15:04", input.Date+" "+input.EndTime, config.Location)
+		if errS != nil || errE != nil || !e.After(s) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "date must be YYYY-MM-DD and start_time before end_time (HH:MM)"})
+			return
+		}
+		start, end = &s, &e
+	}
+
+	assignments, err := repository.AssignOrderToCrew(uint(id), input.CrewID, input.ItemIDs, start, end, input.Note)
+	var conflict *repository.AssignmentConflictError
+	switch {
+	case errors.As(err, &conflict):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "conflicts": conflict.Conflicts})
+		return
+	case errors.Is(err, repository.ErrCrewInactive), errors.Is(err, repository.ErrOrderClosed):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, repository.ErrCrewNotFound), errors.Is(err, repository.ErrOrderNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, repository.ErrNoServiceItems), errors.Is(err, repository.ErrAssignmentWindow):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, assignments)
+}
+
+// DeleteAssignment removes a crew assignment.
+func DeleteAssignment(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid assignment ID"})
+		return
+	}
+	err = repository.DeleteAssignment(uint(id))
+	if errors.Is(err, repository.ErrAssignmentNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetCrewSchedule returns a crew's jobs on ?date=YYYY-MM-DD (today by default).
+func GetCrewSchedule(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid crew ID"})
+		return
+	}
+
+	day := time.Now().In(config.Location)
+	if v := c.Query("date"); v != "" {
+		day, err = time.ParseInLocation("2006-01-02", v, config.Location)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "date must be YYYY-MM-DD"})
+			return
+		}
+	}
+
+	assignments, err := repository.GetCrewSchedule(uint(id), day)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, assignments)
+}
@@ -0,0 +1,133 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"bogbon-api/models"
+	"bogbon-api/repository"
+	"bogbon-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Register creates a customer account and logs the current session into it.
+func Register(c *gin.Context) {
+	var input struct {
+		Name     string `json:"name"`
+		Phone    string `json:"phone"`
+		Email    string `json:"email" binding:"omitempty,email"`
+		Password string `json:"password" binding:"required,min=8"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var phone, email *string
+	if input.Phone != "" {
+		p, ok := utils.NormalizePhone(input.Phone)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid phone number"})
+			return
+		}
+		phone = &p
+	}
+	if e := strings.ToLower(strings.TrimSpace(input.Email)); e != "" {
+		email = &e
+	}
+	if phone == nil && email == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "phone or email is required"})
+		return
+	}
+
+	customer, err := repository.RegisterCustomer(strings.TrimSpace(input.Name), phone, email, input.Password)
+	if errors.Is(err, repository.ErrCustomerExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := loginCustomer(c, customer); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, customer)
+}
+
+// Login authenticates a customer by phone or email and password.
+func Login(c *gin.Context) {
+	var input struct {
+		Login    string `json:"login" binding:"required"` // phone or email
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	login := strings.TrimSpace(input.Login)
+	if phone, ok := utils.NormalizePhone(login); ok {
+		login = phone
+	}
+
+	customer, err := repository.AuthenticateCustomer(login, input.Password)
+	if errors.Is(err, repository.ErrInvalidCredentials) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid login or password"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := loginCustomer(c, customer); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, customer)
+}
+
+// Logout detaches the session from the customer account.
+func Logout(c *gin.Context) {
+	if err := utils.LogoutSession(c); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Me returns the logged-in customer.
+func Me(c *gin.Context) {
+	customer, err := repository.GetCustomerByID(c.GetUint("customer_id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "customer not found"})
+		return
+	}
+	c.JSON(http.StatusOK, customer)
+}
+
+// MyOrders returns the logged-in customer's order history.
+func MyOrders(c *gin.Context) {
+	orders, err := repository.GetOrdersByCustomer(c.GetUint("customer_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, localizeOrders(c, orders))
+}
+
+// loginCustomer merges the current anonymous session into the account and
+// switches the session over to it.
+func loginCustomer(c *gin.Context, customer *models.Customer) error {
+	if _, loggedIn := utils.GetCustomerID(c); !loggedIn {
+		if err := repository.MergeSessionIntoCustomer(utils.GetSessionID(c), customer); err != nil {
+			return err
+		}
+	}
+	return utils.LoginSession(c, customer.ID, customer.SessionID)
+}
@@ -0,0 +1,127 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"bogbon-api/images"
+	"bogbon-api/models"
+	"bogbon-api/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// imageSize reads ?image_size=, one of the configured renditions or
+// "original", falling back to def. It answers 400 itself for unknown sizes.
+func imageSize(c *gin.Context, def string) (string, bool) {
+	size := c.DefaultQuery("image_size", def)
+	if size == images.Original {
+		return size, true
+	}
+	if _, ok := images.Lookup(size); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown image_size " + size})
+		return "", false
+	}
+	return size, true
+}
+
+// applyImageSize points the URL of every image at its size rendition. All
+// renditions stay listed under Renditions.
+func applyImageSize(imgs []models.ProductImage, size string) {
+	if size == images.Original {
+		return
+	}
+	for i := range imgs {
+		imgs[i].URL = imgs[i].RenditionURL(size)
+	}
+}
+
+// GetImageJob reports the processing of an uploaded image: pending,
+// processing, done (with the image and its renditions) or failed (with the
+// last error). Failed attempts are retried a few times before failing.
+func GetImageJob(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job ID"})
+		return
+	}
+	job, err := repository.GetImageJob(uint(id))
+	if errors.Is(err, repository.ErrImageJobNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// ReorderImages sets the display order and cover of a product's images:
+// {"image_ids": [7, 3, 5], "primary_image_id": 3}. Either field may be left
+// out; image_ids must list every image of the product.
+func ReorderImages(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+	var input struct {
+		ImageIDs       []uint `json:"image_ids"`
+		PrimaryImageID *uint  `json:"primary_image_id"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	imgs, err := repository.ReorderProductImages(uint(id), input.ImageIDs, input.PrimaryImageID)
+	if err != nil {
+		respondImageError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, imgs)
+}
+
+// SetImageTranslations replaces the alt text and caption of an image per
+// language: {"translations": {"en": {"alt": "...", "caption": "..."}}}.
+func SetImageTranslations(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid image ID"})
+		return
+	}
+	var input struct {
+		Translations map[string]struct {
+			Alt     string `json:"alt" binding:"max=255"`
+			Caption string `json:"caption"`
+		} `json:"translations" binding:"required,dive"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var translations []models.ImageTranslation
+	for lang, t := range input.Translations {
+		translations = append(translations, models.ImageTranslation{LanguageCode: lang, Alt: t.Alt, Caption: t.Caption})
+	}
+	img, err := repository.SetImageTranslations(uint(id), translations)
+	if err != nil {
+		respondImageError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, img)
+}
+
+func respondImageError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, repository.ErrImageOrder), errors.Is(err, repository.ErrImageMismatch):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, repository.ErrProductNotFound), errors.Is(err, repository.ErrImageNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"bogbon-api/models"
+	"bogbon-api/responses"
+	"bogbon-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// flatLanguages reports whether the client asked for ?flat=true, in which case
+// translated fields are flattened into the chosen language. It returns the
+// language preference and announces the first choice in Content-Language.
+func flatLanguages(c *gin.Context) ([]string, bool) {
+	if c.Query("flat") != "true" {
+		return nil, false
+	}
+	langs := utils.Languages(c)
+	c.Header("Content-Language", langs[0])
+	return langs, true
+}
+
+func localizeProduct(c *gin.Context, p models.Product) interface{} {
+	if langs, ok := flatLanguages(c); ok {
+		return responses.NewProduct(p, langs)
+	}
+	return p
+}
+
+func localizeProducts(c *gin.Context, ps []models.Product) interface{} {
+	if langs, ok := flatLanguages(c); ok {
+		return responses.NewProducts(ps, langs)
+	}
+	return ps
+}
+
+func localizeCategories(c *gin.Context, cs []models.Category) interface{} {
+	if langs, ok := flatLanguages(c); ok {
+		return responses.NewCategories(cs, langs)
+	}
+	return cs
+}
+
+func localizeCartItems(c *gin.Context, items []models.CartItem) interface{} {
+	if langs, ok := flatLanguages(c); ok {
+		return responses.NewCartItems(items, langs)
+	}
+	return items
+}
+
+func localizeOrder(c *gin.Context, o models.Order) interface{} {
+	if langs, ok := flatLanguages(c); ok {
+		return responses.NewOrder(o, langs)
+	}
+	return o
+}
+
+func localizeOrders(c *gin.Context, os []models.Order) interface{} {
+	if langs, ok := flatLanguages(c); ok {
+		return responses.NewOrders(os, langs)
+	}
+	return os
+}
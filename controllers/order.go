@@ -1,9 +1,15 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"bogbon-api/models"
 	"bogbon-api/repository"
+	"bogbon-api/requests"
 	"bogbon-api/utils"
 
 	"github.com/gin-gonic/gin"
@@ -12,20 +18,55 @@ import (
 // CreateOrder godoc
 // @Summary Create a new order from the current cart
 // @Tags Orders
+// @Accept json
 // @Produce json
+// @Param input body requests.CheckoutInput true "Contact and delivery details"
 // @Success 201 {object} models.Order
 // @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]interface{}
 // @Router /orders [post]
 
 // CreateOrder creates an order from the current cart.
 func CreateOrder(c *gin.Context) {
+	var input requests.CheckoutInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	delivery, err := deliveryFromInput(input.DeliveryInput)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	slots := make(map[uint]time.Time, len(input.Slots))
+	for _, s := range input.Slots {
+		slots[s.CartItemID] = s.SlotStart
+	}
+
 	sessionID := utils.GetSessionID(c)
-	order, err := repository.CreateOrderFromCart(sessionID)
+	order, err := repository.CreateOrderFromCart(sessionID, delivery, slots)
+	var shortage *repository.InsufficientStockError
+	if errors.As(err, &shortage) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "items": shortage.Items})
+		return
+	}
+	var slotErr *repository.SlotError
+	if errors.As(err, &slotErr) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "cart_item_id": slotErr.CartItemID})
+		return
+	}
+	var variantErr *repository.VariantError
+	if errors.As(err, &variantErr) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "cart_item_id": variantErr.CartItemID})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusCreated, order)
+	c.JSON(http.StatusCreated, localizeOrder(c, *order))
 }
 
 // GetOrder godoc
@@ -44,48 +85,62 @@ func GetOrder(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
 		return
 	}
-	c.JSON(http.StatusOK, order)
+	c.JSON(http.StatusOK, localizeOrder(c, *order))
 }
 
 // ListOrders godoc
 // @Summary List all orders (admin use)
 // @Tags Orders
 // @Produce json
-// @Success 200 {array} models.Order
+// @Param page query int false "Page number, from 1"
+// @Param per_page query int false "Page size (max 100)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "created_at, total; prefix with - for descending (default -created_at)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /orders/all [get]
 
-// ListOrders returns all orders (admin use).
+// ListOrders returns a page of all orders (admin use).
 func ListOrders(c *gin.Context) {
-	orders, err := repository.GetAllOrders()
+	p, ok := pageParams(c)
+	if !ok {
+		return
+	}
+	orders, meta, err := repository.GetAllOrders(p)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondListError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, orders)
+	c.JSON(http.StatusOK, gin.H{"data": localizeOrders(c, orders), "meta": meta})
 }
 
 // UpdateOrder godoc
-// @Summary Update the payment status of an order
+// @Summary Cancel the most recent order for the current session
 // @Tags Orders
 // @Accept json
 // @Produce json
-// @Param status body struct{IsPaid bool `json:"is_paid"`} true "Order payment status"
+// @Param status body struct{Status string `json:"status"`} true "Must be \"cancelled\""
 // @Success 200 {object} models.Order
 // @Failure 400 {object} map[string]string
 // @Failure 404 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Router /orders [put]
 
-// UpdateOrder marks an order paid/unpaid.
+// UpdateOrder lets a customer cancel their latest order. Every other status
+// change goes through the admin endpoint.
 func UpdateOrder(c *gin.Context) {
 	var input struct {
-		IsPaid bool `json:"is_paid"`
+		Status string `json:"status" binding:"required"`
 	}
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if input.Status != models.OrderCancelled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "customers can only cancel orders"})
+		return
+	}
 
 	sessionID := utils.GetSessionID(c)
 	order, err := repository.GetOrderBySession(sessionID)
@@ -94,30 +149,115 @@ func UpdateOrder(c *gin.Context) {
 		return
 	}
 
-	order.IsPaid = input.IsPaid
-	if err := repository.UpdateOrder(order); err != nil {
+	order, err = repository.TransitionOrder(order.ID, models.OrderCancelled, nil, "cancelled by customer")
+	if err != nil {
+		respondTransitionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, localizeOrder(c, *order))
+}
+
+// AdminGetOrder returns a single order with its status history.
+func AdminGetOrder(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid order ID"})
+		return
+	}
+	order, err := repository.GetOrderByID(uint(id))
+	if errors.Is(err, repository.ErrOrderNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	c.JSON(http.StatusOK, localizeOrder(c, *order))
+}
+
+// AdvanceOrderStatus moves an order to a new status (admin use).
+func AdvanceOrderStatus(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid order ID"})
+		return
+	}
+
+	var input struct {
+		Status string `json:"status" binding:"required"`
+		Note   string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	adminID := c.GetUint("admin_id")
+	order, err := repository.TransitionOrder(uint(id), input.Status, &adminID, input.Note)
+	if err != nil {
+		respondTransitionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, localizeOrder(c, *order))
+}
+
+// deliveryFromInput validates contact and address fields and normalizes them for storage.
+func deliveryFromInput(input requests.DeliveryInput) (models.DeliveryDetails, error) {
+	phone, ok := utils.NormalizePhone(input.Phone)
+	if !ok {
+		return models.DeliveryDetails{}, errors.New("invalid phone number")
+	}
+	if (input.Latitude == nil) != (input.Longitude == nil) {
+		return models.DeliveryDetails{}, errors.New("latitude and longitude must be given together")
+	}
+	if input.ContactMethod == "" {
+		input.ContactMethod = models.ContactPhone
+	}
+
+	return models.DeliveryDetails{
+		CustomerName:  strings.TrimSpace(input.Name),
+		Phone:         phone,
+		Region:        strings.TrimSpace(input.Region),
+		District:      strings.TrimSpace(input.District),
+		Street:        strings.TrimSpace(input.Street),
+		Latitude:      input.Latitude,
+		Longitude:     input.Longitude,
+		ContactMethod: input.ContactMethod,
+		Notes:         strings.TrimSpace(input.Notes),
+	}, nil
+}
 
-	c.JSON(http.StatusOK, order)
+func respondTransitionError(c *gin.Context, err error) {
+	var invalid *repository.InvalidTransitionError
+	switch {
+	case errors.Is(err, repository.ErrOrderNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.As(err, &invalid):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
 }
 
-// DeleteOrder godoc
-// @Summary Delete all orders for the current session
+// CancelPendingOrders godoc
+// @Summary Cancel all pending orders for the current session
 // @Tags Orders
 // @Produce json
-// @Success 200 {object} map[string]string
+// @Success 200 {object} map[string]interface{}
 // @Failure 500 {object} map[string]string
 // @Router /orders [delete]
 
-// DeleteOrder deletes all orders for this session.
-func DeleteOrder(c *gin.Context) {
+// CancelPendingOrders cancels this session's pending orders. Orders are
+// financial records and are never deleted.
+func CancelPendingOrders(c *gin.Context) {
 	sessionID := utils.GetSessionID(c)
-	if err := repository.DeleteOrderBySession(sessionID); err != nil {
+	n, err := repository.CancelPendingOrdersBySession(sessionID)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "orders deleted"})
+	c.JSON(http.StatusOK, gin.H{"message": "pending orders cancelled", "cancelled": n})
 }
 
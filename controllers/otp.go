@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"bogbon-api/repository"
+	"bogbon-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestOTP sends a one-time login code to a +998 phone number.
+func RequestOTP(c *gin.Context) {
+	var input struct {
+		Phone string `json:"phone" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	phone, ok := utils.NormalizePhone(input.Phone)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid phone number"})
+		return
+	}
+
+	code, err := repository.CreateOTP(phone)
+	if errors.Is(err, repository.ErrOTPRateLimited) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := utils.SMS.Send(phone, "Bogbon login code: "+code); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "could not send code"})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "code sent"})
+}
+
+// VerifyOTP checks the code and logs the session in, creating the account on first login.
+func VerifyOTP(c *gin.Context) {
+	var input struct {
+		Phone string `json:"phone" binding:"required"`
+		Code  string `json:"code" binding:"required,len=6,numeric"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	phone, ok := utils.NormalizePhone(input.Phone)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid phone number"})
+		return
+	}
+
+	err := repository.VerifyOTP(phone, input.Code)
+	switch {
+	case errors.Is(err, repository.ErrOTPTooManyAttempts):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, repository.ErrOTPInvalid), errors.Is(err, repository.ErrOTPExpired):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	customer, err := repository.FindOrCreateCustomerByPhone(phone)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := loginCustomer(c, customer); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, customer)
+}
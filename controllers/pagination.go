@@ -0,0 +1,55 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"bogbon-api/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPerPage = 20
+	maxPerPage     = 100
+)
+
+// pageParams reads ?page=&per_page=&cursor=&sort= and answers 400 itself
+// when they are malformed.
+func pageParams(c *gin.Context) (repository.PageParams, bool) {
+	p := repository.PageParams{
+		Page:    1,
+		PerPage: defaultPerPage,
+		Cursor:  c.Query("cursor"),
+		Sort:    c.Query("sort"),
+	}
+	if v := c.Query("page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid page"})
+			return p, false
+		}
+		p.Page = n
+	}
+	if v := c.Query("per_page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid per_page"})
+			return p, false
+		}
+		p.PerPage = min(n, maxPerPage)
+	}
+	return p, true
+}
+
+// respondListError maps list errors to 400 for bad sort, cursor or attribute
+// filter values and 500 otherwise.
+func respondListError(c *gin.Context, err error) {
+	var attrErr *repository.AttributeValueError
+	if errors.Is(err, repository.ErrInvalidSort) || errors.Is(err, repository.ErrInvalidCursor) || errors.As(err, &attrErr) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
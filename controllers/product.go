@@ -1,26 +1,27 @@
 package controllers
 
 import (
-	"fmt"
-	"image"
-	"image/png"
+	"errors"
+	"io"
+	"mime/multipart"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strconv"
-	"strings"
 
+	"bogbon-api/images"
 	"bogbon-api/models"
 	"bogbon-api/repository"
+	"bogbon-api/utils"
 
-	"github.com/chai2010/webp"
-	"github.com/disintegration/imaging"
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
-	"github.com/nfnt/resize"
 )
 
-// ListProducts now supports ?min_price=&max_price=&type=&in_stock=&category=&q=&include_images=true
+// ListProducts now supports ?min_price=&max_price=&type=&in_stock=&category=&include_descendants=&q=&lang=
+// plus ?page=&per_page=&cursor=&sort=price|-price|created_at|name|popularity
+// and ?include_facets=true for category, type, price and stock counts.
+// ?attr[code]=value filters by specification: attr[light]=bright,partial_shade,
+// attr[height_cm]=50..120, attr[pet_safe]=true.
+// ?flat=true returns name, description and short_info in the negotiated language.
+// ?image_size=thumb|card|detail|zoom|og-image|original picks the image URLs.
 func ListProducts(c *gin.Context) {
 	var f repository.ProductFilter
 
@@ -55,22 +56,23 @@ func ListProducts(c *gin.Context) {
 		f.InStock = &b
 	}
 
-	// in_resized
-	// is_original
+	// image_size picks the rendition returned as each image's URL. The legacy
+	// ?is_original=true asks for the uploaded originals, otherwise cards.
+	def := "card"
 	if v := c.Query("is_original"); v != "" {
 		r, err := strconv.ParseBool(v)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid is_original"})
 			return
 		}
-		f.IsOriginal = r
-	} else {
-		// If `is_original` is not provided, you can set it to false (or true, depending on your default behavior).
-		f.IsOriginal = false // Default to `false` if not specified
+		if r {
+			def = images.Original
+		}
+	}
+	size, ok := imageSize(c, def)
+	if !ok {
+		return
 	}
-
-	fmt.Printf("isORIGINAL: ")
-	fmt.Println(f.IsOriginal)
 
 	// category (can be repeated)
 	for _, v := range c.QueryArray("category") {
@@ -85,19 +87,52 @@ func ListProducts(c *gin.Context) {
 		}
 	}
 
-	// search term
+	// include_descendants: also match products of subcategories
+	if v := c.Query("include_descendants"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid include_descendants"})
+			return
+		}
+		f.SubCategories = b
+	}
+
+	// attr[code]: specification filters, checked against the attribute definitions
+	if attrs := c.QueryMap("attr"); len(attrs) > 0 {
+		f.Attributes = attrs
+	}
+
+	// search term, matched against the translation in the negotiated language
 	f.Q = c.Query("q")
+	f.Lang = utils.Language(c)
 
-	// fetch products, including images
-	includeImages := c.DefaultQuery("include_images", "false") != "true"
-	products, err := repository.FilterProducts(f, includeImages)
+	p, ok := pageParams(c)
+	if !ok {
+		return
+	}
+
+	products, meta, err := repository.FilterProducts(f, p)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondListError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, products)
+	for i := range products {
+		applyImageSize(products[i].Images, size)
+	}
+	resp := gin.H{"data": localizeProducts(c, products), "meta": meta}
+	if c.Query("include_facets") == "true" {
+		facets, err := repository.ProductFacets(f)
+		if err != nil {
+			respondListError(c, err)
+			return
+		}
+		resp["facets"] = facets
+	}
+	c.JSON(http.StatusOK, resp)
 }
 
+// GetProduct returns one product. ?image_size= picks the image URLs like in
+// ListProducts; by default they point at the originals.
 func GetProduct(c *gin.Context) {
 	idParam := c.Param("id")
 	id, err := strconv.ParseUint(idParam, 10, 32)
@@ -105,14 +140,47 @@ func GetProduct(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
 		return
 	}
+	size, ok := imageSize(c, images.Original)
+	if !ok {
+		return
+	}
 
 	product, err := repository.GetProductByID(uint(id))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "product not found"})
 		return
 	}
+	applyImageSize(product.Images, size)
 
-	c.JSON(http.StatusOK, product)
+	c.JSON(http.StatusOK, localizeProduct(c, *product))
+}
+
+// GetProductBySlug looks a product up by its slug in one language, e.g.
+// /api/products/by-slug/uz/orik-kochati. Former slugs answer with a 301 to
+// the current one.
+func GetProductBySlug(c *gin.Context) {
+	lang, slug := c.Param("lang"), c.Param("slug")
+	id, current, redirected, err := repository.ResolveSlug(models.SlugProduct, lang, slug)
+	if err != nil {
+		respondSlugError(c, err)
+		return
+	}
+	if redirected && current != "" {
+		redirectToSlug(c, "/api/products/by-slug/", lang, current)
+		return
+	}
+	size, ok := imageSize(c, images.Original)
+	if !ok {
+		return
+	}
+
+	product, err := repository.GetProductByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "product not found"})
+		return
+	}
+	applyImageSize(product.Images, size)
+	c.JSON(http.StatusOK, localizeProduct(c, *product))
 }
 
 // CreateProduct godoc
@@ -218,105 +286,93 @@ func UpdateProduct(c *gin.Context) {
 	c.JSON(http.StatusOK, product)
 }
 
-// UploadProductImage handles image upload for a specific product (multiple images)
+// UploadProductImage handles image upload for a specific product (multiple images).
+// The originals are stored right away and their renditions are generated in
+// the background: the response is 202 with one job per file, whose progress
+// GET /api/images/jobs/:id reports.
 func UploadProductImage(c *gin.Context) {
+	jobs, ok := queueUploadedImages(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "Images uploaded, processing", "jobs": jobs})
+}
+
+// queueUploadedImages stores the "images" files of the request for the
+// product in the :id path parameter and queues their processing. It writes
+// the error response itself.
+func queueUploadedImages(c *gin.Context) ([]models.ImageJob, bool) {
 	idParam := c.Param("id")
 	id, err := strconv.Atoi(idParam)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
-		return
+		return nil, false
+	}
+	exists, err := repository.ProductExists(uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "product not found"})
+		return nil, false
 	}
 
 	// Parse the multipart form (to handle file uploads)
 	err = c.Request.ParseMultipartForm(10 << 20) // 10 MB limit
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to parse multipart form"})
-		return
+		return nil, false
 	}
 
 	// Get the uploaded files
 	files := c.Request.MultipartForm.File["images"]
 	if len(files) == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one image file is required"})
-		return
+		return nil, false
 	}
-
-	// Loop over each uploaded file
 	for _, file := range files {
-		ext := strings.ToLower(filepath.Ext(file.Filename))
-		if ext != ".jpg" && ext != ".jpeg" && ext != ".png" {
+		if !images.Supported(file.Filename) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Only JPG, JPEG, or PNG files are allowed"})
-			return
+			return nil, false
 		}
+	}
 
-		// Open the file
-		srcFile, err := file.Open()
+	// Store each original; renditions are left to the image workers
+	var jobs []models.ImageJob
+	for _, file := range files {
+		data, err := readUpload(file)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open uploaded file"})
-			return
+			return nil, false
 		}
-		defer srcFile.Close()
 
-		// Decode image
-		maxImage, _, err := image.Decode(srcFile)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image format"})
-			return
+		key, err := images.StoreOriginal(file.Filename, data)
+		if errors.Is(err, images.ErrInvalidImage) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return nil, false
 		}
-
-		// Resize the image
-		minImage := resize.Resize(0, 305, maxImage, resize.Lanczos3)
-
-		// Extract the base name without the extension
-		fileName := file.Filename
-		baseName := fileName[:len(fileName)-len(filepath.Ext(fileName))]
-
-		// Generate a unique filename
-		imageUUID := uuid.New().String()
-		filename := fmt.Sprintf(baseName+"_product_%s.webp", imageUUID)
-		minFullPath := filepath.Join("uploads/min_uploads", filename)
-		maxFullPath := filepath.Join("uploads/max_uploads", filename)
-
-		// Create file to save WebP image
-		minFile, err := os.Create(minFullPath)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create min WebP file"})
-			return
-		}
-		defer minFile.Close()
-
-		maxFile, err := os.Create(maxFullPath)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create max WebP file"})
-			return
-		}
-		defer maxFile.Close()
-
-		// Encode and save the images as WebP
-		err = webp.Encode(minFile, minImage, &webp.Options{Quality: 75})
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode min image to WebP"})
-			return
-		}
-
-		err = webp.Encode(maxFile, maxImage, &webp.Options{Quality: 75})
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode max image to WebP"})
-			return
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store image"})
+			return nil, false
 		}
+		jobs = append(jobs, models.ImageJob{ProductID: uint(id), Filename: file.Filename, SourceKey: key})
+	}
 
-		// Update the product image in the repository
-		if err := repository.UpdateProductImage(uint(id), minFullPath, false); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product image"})
-			return
-		}
-		if err := repository.UpdateProductImage(uint(id), maxFullPath, true); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product image"})
-			return
-		}
+	if err := repository.CreateImageJobs(jobs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue image processing"})
+		return nil, false
 	}
+	return jobs, true
+}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Images uploaded successfully"})
+func readUpload(file *multipart.FileHeader) ([]byte, error) {
+	src, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	return io.ReadAll(src)
 }
 
 func DeleteProduct(c *gin.Context) {
@@ -344,7 +400,7 @@ func DeleteImage(c *gin.Context) {
 	}
 
 	if err := repository.DeleteProductImage(uint(id)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondImageError(c, err)
 		return
 	}
 
@@ -353,82 +409,9 @@ func DeleteImage(c *gin.Context) {
 
 // UpdateProductImageByID updates images for a specific product (multiple images)
 func UpdateProductImageByID(c *gin.Context) {
-	idParam := c.Param("id")
-	id, err := strconv.Atoi(idParam)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
-		return
-	}
-
-	// Parse the multipart form (to handle file uploads)
-	err = c.Request.ParseMultipartForm(10 << 20) // 10 MB limit
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to parse multipart form"})
+	jobs, ok := queueUploadedImages(c)
+	if !ok {
 		return
 	}
-
-	// Get the uploaded files
-	files := c.Request.MultipartForm.File["images"]
-	if len(files) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one image file is required"})
-		return
-	}
-
-	// Loop over each uploaded file
-	for _, file := range files {
-		ext := strings.ToLower(filepath.Ext(file.Filename))
-		if ext != ".jpg" && ext != ".jpeg" && ext != ".png" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Only JPG, JPEG, or PNG files are allowed"})
-			return
-		}
-
-		// Open the file
-		srcFile, err := file.Open()
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open uploaded file"})
-			return
-		}
-		defer srcFile.Close()
-
-		// Decode image
-		maxImage, _, err := image.Decode(srcFile)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image format"})
-			return
-		}
-
-		// Resize the image
-		minImage := imaging.Resize(maxImage, 0, 305, imaging.Lanczos)
-
-		// Generate a unique filename
-		imageUUID := uuid.New().String()
-		filename := fmt.Sprintf("product_%s%s", imageUUID, ext)
-		minFullPath := filepath.Join("uploads/min_uploads", filename)
-		maxFullPath := filepath.Join("uploads/max_uploads", filename)
-
-		// Compress based on file type
-		switch ext {
-		case ".jpg", ".jpeg":
-			err = imaging.Save(minImage, minFullPath, imaging.JPEGQuality(70))
-			err = imaging.Save(maxImage, maxFullPath, imaging.JPEGQuality(70))
-		case ".png":
-			err = imaging.Save(minImage, minFullPath, imaging.PNGCompressionLevel(png.BestCompression))
-			err = imaging.Save(maxImage, maxFullPath, imaging.PNGCompressionLevel(png.BestCompression))
-		default:
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported file type"})
-			return
-		}
-
-		// Update the product image in the repository
-		if err := repository.UpdateProductImage(uint(id), minFullPath, false); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product image"})
-			return
-		}
-		if err := repository.UpdateProductImage(uint(id), maxFullPath, true); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product image"})
-			return
-		}
-	}
-
-	c.JSON(http.StatusOK, gin.H{"message": "Images updated successfully"})
+	c.JSON(http.StatusAccepted, gin.H{"message": "Images uploaded, processing", "jobs": jobs})
 }
@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"bogbon-api/config"
+	"bogbon-api/models"
+	"bogbon-api/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetServiceSchedule returns the weekly working hours of a service product.
+func GetServiceSchedule(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+
+	schedule, err := repository.GetServiceSchedule(uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, schedule)
+}
+
+// SetServiceSchedule replaces the weekly working hours and slot capacity of a service.
+func SetServiceSchedule(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+
+	var input []struct {
+		Weekday     int    `json:"weekday" binding:"gte=0,lte=6"`
+		StartTime   string `json:"start_time" binding:"required"`
+		EndTime     string `json:"end_time" binding:"required"`
+		SlotMinutes int    `json:"slot_minutes" binding:"required,gte=1"`
+		Capacity    int    `json:"capacity" binding:"required,gte=1"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	schedule := make([]models.ServiceSchedule, len(input))
+	for i, s := range input {
+		schedule[i] = models.ServiceSchedule{
+			Weekday:     s.Weekday,
+			StartTime:   s.StartTime,
+			EndTime:     s.EndTime,
+			SlotMinutes: s.SlotMinutes,
+			Capacity:    s.Capacity,
+		}
+	}
+
+	err = repository.SetServiceSchedule(uint(id), schedule)
+	switch {
+	case errors.Is(err, repository.ErrInvalidSchedule), errors.Is(err, repository.ErrNotAService):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, schedule)
+}
+
+// ListServiceSlots returns the bookable slots of a service on ?date=YYYY-MM-DD.
+func ListServiceSlots(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+
+	day, err := time.ParseInLocation("2006-01-02", c.Query("date"), config.Location)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "date must be YYYY-MM-DD"})
+		return
+	}
+
+	slots, err := repository.AvailableSlots(uint(id), day)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, slots)
+}
@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"bogbon-api/repository"
+	"bogbon-api/search"
+	"bogbon-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListSynonyms returns all search synonym pairs.
+func ListSynonyms(c *gin.Context) {
+	synonyms, err := repository.GetAllSynonyms()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, synonyms)
+}
+
+// CreateSynonyms links a term to one or more synonyms, e.g. "atirgul" -> ["roza", "rose"].
+func CreateSynonyms(c *gin.Context) {
+	var input struct {
+		Term     string   `json:"term" binding:"required"`
+		Synonyms []string `json:"synonyms" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	created, err := repository.AddSynonyms(input.Term, input.Synonyms)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+// DeleteSynonym removes a synonym pair by ID.
+func DeleteSynonym(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ID"})
+		return
+	}
+	if err := repository.DeleteSynonym(uint(id)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// SuggestSearch completes a partially typed query with product and category
+// names, e.g. GET /api/search/suggest?q=kak&lang=uz&limit=5.
+func SuggestSearch(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+		return
+	}
+	limit = min(limit, 20)
+
+	c.JSON(http.StatusOK, search.Suggestions.Suggest(c.Query("q"), utils.Language(c), limit))
+}
@@ -0,0 +1,29 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"bogbon-api/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// redirectToSlug answers a request for a former slug with a permanent
+// redirect to the current one, keeping the query string.
+func redirectToSlug(c *gin.Context, prefix, lang, slug string) {
+	location := prefix + url.PathEscape(lang) + "/" + url.PathEscape(slug)
+	if c.Request.URL.RawQuery != "" {
+		location += "?" + c.Request.URL.RawQuery
+	}
+	c.Redirect(http.StatusMovedPermanently, location)
+}
+
+func respondSlugError(c *gin.Context, err error) {
+	if errors.Is(err, repository.ErrSlugNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"bogbon-api/config"
+	"bogbon-api/models"
+	"bogbon-api/repository"
+	"bogbon-api/requests"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateSubscription subscribes the logged-in customer to a recurring service.
+func CreateSubscription(c *gin.Context) {
+	var input requests.SubscriptionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	delivery, err := deliveryFromInput(input.DeliveryInput)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.TimeOfDay != "" {
+		if _, err := time.Parse("15:04", input.TimeOfDay); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "time_of_day must be HH:MM"})
+			return
+		}
+	}
+	start := time.Now().In(config.Location)
+	if input.StartDate != "" {
+		start, err = time.ParseInLocation("2006-01-02", input.StartDate, config.Location)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "start_date must be YYYY-MM-DD"})
+			return
+		}
+		y, m, d := time.Now().In(config.Location).Date()
+		if start.Before(time.Date(y, m, d, 0, 0, 0, 0, config.Location)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "start_date cannot be in the past"})
+			return
+		}
+	}
+	if input.Quantity == 0 {
+		input.Quantity = 1
+	}
+
+	sub := models.Subscription{
+		CustomerID:      c.GetUint("customer_id"),
+		ProductID:       input.ProductID,
+		Quantity:        input.Quantity,
+		Frequency:       input.Frequency,
+		Weekday:         input.Weekday,
+		TimeOfDay:       input.TimeOfDay,
+		StartDate:       start,
+		DeliveryDetails: delivery,
+	}
+	err = repository.CreateSubscription(&sub)
+	if errors.Is(err, repository.ErrNotAService) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, sub)
+}
+
+// ListSubscriptions returns the logged-in customer's subscriptions.
+func ListSubscriptions(c *gin.Context) {
+	subs, err := repository.GetSubscriptionsByCustomer(c.GetUint("customer_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, subs)
+}
+
+// PauseSubscription stops generating orders for a subscription.
+func PauseSubscription(c *gin.Context) {
+	changeSubscription(c, repository.PauseSubscription)
+}
+
+// ResumeSubscription restarts a paused subscription.
+func ResumeSubscription(c *gin.Context) {
+	changeSubscription(c, repository.ResumeSubscription)
+}
+
+// CancelSubscription ends a subscription.
+func CancelSubscription(c *gin.Context) {
+	changeSubscription(c, repository.CancelSubscription)
+}
+
+// SkipSubscriptionDate opts out of a single upcoming occurrence.
+func SkipSubscriptionDate(c *gin.Context) {
+	var input requests.SkipDateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	date, err := time.ParseInLocation("2006-01-02", input.Date, config.Location)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "date must be YYYY-MM-DD"})
+		return
+	}
+
+	changeSubscription(c, func(customerID, id uint) (*models.Subscription, error) {
+		return repository.SkipSubscriptionDate(customerID, id, date)
+	})
+}
+
+func changeSubscription(c *gin.Context, change func(customerID, id uint) (*models.Subscription, error)) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid subscription ID"})
+		return
+	}
+
+	sub, err := change(c.GetUint("customer_id"), uint(id))
+	var invalid *repository.InvalidTransitionError
+	switch {
+	case errors.Is(err, repository.ErrSubscriptionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, repository.ErrNotAnOccurrence):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, repository.ErrSubscriptionState), errors.Is(err, repository.ErrSubscriptionInactive),
+		errors.As(err, &invalid):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusOK, sub)
+	}
+}
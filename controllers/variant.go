@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"bogbon-api/models"
+	"bogbon-api/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+type variantInput struct {
+	SKU     string `json:"sku" binding:"required,max=64"`
+	Price   int    `json:"price" binding:"gte=0"`
+	Stock   int    `json:"stock" binding:"gte=0"`
+	Options []struct {
+		Name  string `json:"name" binding:"required,max=50"`
+		Value string `json:"value" binding:"required,max=100"`
+	} `json:"options" binding:"dive"`
+}
+
+func (in variantInput) model() models.ProductVariant {
+	v := models.ProductVariant{SKU: in.SKU, Price: in.Price, Stock: in.Stock}
+	for _, o := range in.Options {
+		v.Options = append(v.Options, models.VariantOption{Name: o.Name, Value: o.Value})
+	}
+	return v
+}
+
+// ListVariants returns the variants of a product, e.g. its pot sizes.
+func ListVariants(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+	variants, err := repository.GetProductVariants(uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, variants)
+}
+
+// CreateVariant adds a variant to a product.
+func CreateVariant(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+	var input variantInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	variant := input.model()
+	variant.ProductID = uint(id)
+	if err := repository.CreateVariant(&variant); err != nil {
+		respondVariantError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, variant)
+}
+
+// UpdateVariant replaces a variant's SKU, price, stock and options.
+func UpdateVariant(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid variant ID"})
+		return
+	}
+	var input variantInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	variant := input.model()
+	variant.ID = uint(id)
+	if err := repository.UpdateVariant(&variant); err != nil {
+		respondVariantError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, variant)
+}
+
+// DeleteVariant removes a variant.
+func DeleteVariant(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid variant ID"})
+		return
+	}
+	if err := repository.DeleteVariant(uint(id)); err != nil {
+		respondVariantError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func respondVariantError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, repository.ErrProductNotFound), errors.Is(err, repository.ErrVariantNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, repository.ErrSKUTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
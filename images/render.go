@@ -0,0 +1,37 @@
+package images
+
+import (
+	"bytes"
+	"image"
+
+	"github.com/chai2010/webp"
+	"github.com/disintegration/imaging"
+)
+
+// Render resizes src to r and encodes it as WebP. Images smaller than an
+// inside box are not enlarged.
+func Render(src image.Image, r Rendition) ([]byte, image.Rectangle, error) {
+	var dst image.Image
+	if r.Fit == FitCover {
+		dst = imaging.Fill(src, r.Width, r.Height, imaging.Center, imaging.Lanczos)
+	} else {
+		dst = fitInside(src, r.Width, r.Height)
+	}
+
+	var buf bytes.Buffer
+	if err := webp.Encode(&buf, dst, &webp.Options{Quality: float32(r.Quality)}); err != nil {
+		return nil, image.Rectangle{}, err
+	}
+	return buf.Bytes(), dst.Bounds(), nil
+}
+
+func fitInside(src image.Image, width, height int) image.Image {
+	b := src.Bounds()
+	if (width == 0 || b.Dx() <= width) && (height == 0 || b.Dy() <= height) {
+		return src
+	}
+	if width == 0 || height == 0 {
+		return imaging.Resize(src, width, height, imaging.Lanczos)
+	}
+	return imaging.Fit(src, width, height, imaging.Lanczos)
+}
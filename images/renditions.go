@@ -0,0 +1,107 @@
+// Package images turns uploaded product photos into the resized copies
+// (renditions) the storefront shows: a square thumbnail, a listing card, a
+// product page image, a zoom image and a social-sharing image.
+package images
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Original names the uploaded file itself when a size is requested.
+const Original = "original"
+
+// Fit modes
+const (
+	FitInside = "inside" // scale down to fit within Width x Height, keeping the aspect ratio
+	FitCover  = "cover"  // scale and crop to exactly Width x Height
+)
+
+// Rendition describes one generated size. A zero Width or Height leaves
+// that side unconstrained (inside fit only).
+type Rendition struct {
+	Name    string `json:"name"`
+	Width   int    `json:"width"`
+	Height  int    `json:"height"`
+	Fit     string `json:"fit"`
+	Quality int    `json:"quality"` // WebP quality, 1-100
+}
+
+// Spec sums up how r is rendered. Stored renditions remember it, so
+// renditions made under an older IMAGE_RENDITIONS can be told apart.
+func (r Rendition) Spec() string {
+	return fmt.Sprintf("%s %dx%d q%d", r.Fit, r.Width, r.Height, r.Quality)
+}
+
+// Renditions is the set of sizes generated for every upload. LoadRenditions
+// replaces it from the environment.
+var Renditions = []Rendition{
+	{Name: "thumb", Width: 160, Height: 160, Fit: FitCover, Quality: 70},
+	{Name: "card", Height: 305, Fit: FitInside, Quality: 75},
+	{Name: "detail", Width: 800, Height: 800, Fit: FitInside, Quality: 80},
+	{Name: "zoom", Width: 1600, Height: 1600, Fit: FitInside, Quality: 85},
+	{Name: "og-image", Width: 1200, Height: 630, Fit: FitCover, Quality: 80},
+}
+
+// LoadRenditions reads IMAGE_RENDITIONS, a JSON array of renditions such as
+// [{"name":"card","height":305,"fit":"inside","quality":75}], and makes it
+// the rendition set. Without the variable the defaults stay.
+func LoadRenditions() error {
+	raw := os.Getenv("IMAGE_RENDITIONS")
+	if raw == "" {
+		return nil
+	}
+	var rs []Rendition
+	if err := json.Unmarshal([]byte(raw), &rs); err != nil {
+		return fmt.Errorf("IMAGE_RENDITIONS: %w", err)
+	}
+	if err := validate(rs); err != nil {
+		return fmt.Errorf("IMAGE_RENDITIONS: %w", err)
+	}
+	Renditions = rs
+	return nil
+}
+
+// Lookup returns the rendition called name.
+func Lookup(name string) (Rendition, bool) {
+	for _, r := range Renditions {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return Rendition{}, false
+}
+
+func validate(rs []Rendition) error {
+	if len(rs) == 0 {
+		return errors.New("at least one rendition is required")
+	}
+	seen := make(map[string]bool, len(rs))
+	for i := range rs {
+		r := &rs[i]
+		if r.Name == "" || r.Name == Original || seen[r.Name] {
+			return fmt.Errorf("rendition %d: name must be unique and not %q", i, Original)
+		}
+		seen[r.Name] = true
+		if r.Fit == "" {
+			r.Fit = FitInside
+		}
+		switch {
+		case r.Fit != FitInside && r.Fit != FitCover:
+			return fmt.Errorf("rendition %s: fit must be %q or %q", r.Name, FitInside, FitCover)
+		case r.Width < 0 || r.Height < 0 || r.Width == 0 && r.Height == 0:
+			return fmt.Errorf("rendition %s: width or height is required", r.Name)
+		case r.Fit == FitCover && (r.Width == 0 || r.Height == 0):
+			return fmt.Errorf("rendition %s: cover needs both width and height", r.Name)
+		}
+		if r.Quality == 0 {
+			r.Quality = 80
+		}
+		if r.Quality < 1 || r.Quality > 100 {
+			return fmt.Errorf("rendition %s: quality must be between 1 and 100", r.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package images
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"path"
+	"strings"
+
+	"bogbon-api/models"
+	"bogbon-api/search"
+	"bogbon-api/storage"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidImage = errors.New("invalid image format")
+
+// MaxPixels caps width × height of uploads. Decoding allocates about four
+// bytes per pixel, so a small file claiming huge dimensions could otherwise
+// exhaust the workers' memory.
+const MaxPixels = 40_000_000
+
+// checkSize reads the image header of data and rejects images above MaxPixels.
+func checkSize(data []byte) error {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > MaxPixels {
+		return fmt.Errorf("%w: %dx%d exceeds %d pixels", ErrInvalidImage, cfg.Width, cfg.Height, MaxPixels)
+	}
+	return nil
+}
+
+// contentTypes lists the upload formats, by file extension.
+var contentTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+}
+
+// Supported reports whether uploads named filename are accepted.
+func Supported(filename string) bool {
+	_, ok := contentTypes[strings.ToLower(path.Ext(filename))]
+	return ok
+}
+
+// StoreOriginal checks that data is a JPG or PNG photo and keeps it as
+// uploaded in storage.Files, returning its key. Renditions come later from
+// StoreRenditions.
+func StoreOriginal(filename string, data []byte) (string, error) {
+	ext := strings.ToLower(path.Ext(filename))
+	contentType, ok := contentTypes[ext]
+	if !ok {
+		return "", ErrInvalidImage
+	}
+	if err := checkSize(data); err != nil {
+		return "", err
+	}
+
+	base := search.Slugify(strings.TrimSuffix(path.Base(filename), path.Ext(filename)))
+	if base == "" {
+		base = "image"
+	}
+	key := "originals/" + base + "_product_" + uuid.New().String() + ext
+	if err := storage.Files.Put(key, bytes.NewReader(data), contentType); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// StoreRenditions generates every rendition of the original under key and
+// keeps them in storage.Files. Rendition keys derive from key, so running it
+// again overwrites rather than duplicates. URLs are left for the caller.
+func StoreRenditions(key string) ([]models.ImageRendition, error) {
+	f, err := storage.Files.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+	// Originals stored before the size check existed are checked here too
+	if err := checkSize(data); err != nil {
+		return nil, err
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+
+	name := strings.TrimSuffix(path.Base(key), path.Ext(key))
+	var renditions []models.ImageRendition
+	for _, r := range Renditions {
+		out, bounds, err := Render(src, r)
+		if err != nil {
+			return nil, fmt.Errorf("rendition %s: %w", r.Name, err)
+		}
+		rkey := "renditions/" + r.Name + "/" + name + ".webp"
+		if err := storage.Files.Put(rkey, bytes.NewReader(out), "image/webp"); err != nil {
+			return nil, err
+		}
+		renditions = append(renditions, models.ImageRendition{
+			Name:       r.Name,
+			Width:      bounds.Dx(),
+			Height:     bounds.Dy(),
+			StorageKey: rkey,
+			Spec:       r.Spec(),
+		})
+	}
+	return renditions, nil
+}
+
+// queued signals idle image workers that jobs were added.
+var queued = make(chan struct{}, 1)
+
+// Notify wakes an idle image worker. Calls coalesce until one drains Queued.
+func Notify() {
+	select {
+	case queued <- struct{}{}:
+	default:
+	}
+}
+
+// Queued delivers a value whenever Notify was called.
+func Queued() <-chan struct{} {
+	return queued
+}
@@ -9,11 +9,17 @@ package main
 import (
 	"log"
 	"os"
+	"strconv"
 	"time"
 
 	"bogbon-api/config"
+	"bogbon-api/images"
 	"bogbon-api/models"
+	"bogbon-api/repository"
 	"bogbon-api/router"
+	"bogbon-api/storage"
+	"bogbon-api/utils"
+	"bogbon-api/workers"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-contrib/sessions"
@@ -43,8 +49,22 @@ func main() {
 		MaxAge:           12 * time.Hour,
 	}))
 
-	// Serve uploaded images
-	r.Static("/uploads", "./uploads")
+	// uploaded files: local disk by default, or an S3-compatible bucket
+	files, err := storage.FromEnv()
+	if err != nil {
+		log.Fatal("Failed to configure storage:", err)
+	}
+	storage.Files = files
+
+	// image sizes generated for uploads
+	if err := images.LoadRenditions(); err != nil {
+		log.Fatal("Failed to configure image renditions:", err)
+	}
+
+	// Serve uploaded images when they live on this machine
+	if local, ok := files.(*storage.Local); ok {
+		r.Static("/uploads", local.Dir)
+	}
 
 	// Limit file size
 	// r.MaxMultipartMemory = 8 << 20 // 8 MB
@@ -69,15 +89,115 @@ func main() {
 		&models.Product{},
 		&models.ProductTranslation{},
 		&models.ProductImage{},
+		&models.ImageRendition{},
+		&models.ImageTranslation{},
+		&models.ImageJob{},
+		&models.ProductVariant{},
+		&models.VariantOption{},
+		&models.AttributeDefinition{},
+		&models.AttributeTranslation{},
+		&models.AttributeValue{},
+		&models.AttributeValueTranslation{},
+		&models.ProductAttribute{},
 		&models.Order{},
 		&models.OrderItem{},
+		&models.OrderItemTranslation{},
+		&models.OrderStatusChange{},
 		&models.Cart{},
 		&models.CartItem{},
+		&models.Admin{},
+		&models.Customer{},
+		&models.OTPCode{},
+		&models.ServiceSchedule{},
+		&models.SlotReservation{},
+		&models.Crew{},
+		&models.Worker{},
+		&models.CrewAssignment{},
+		&models.Subscription{},
+		&models.SubscriptionSkip{},
+		&models.SearchSynonym{},
+		&models.SlugRedirect{},
 	)
 
+	// full-text search column and indexes
+	if err := repository.EnsureSearchIndexes(); err != nil {
+		log.Fatal("Failed to create search indexes:", err)
+	}
+
+	// slugs for translations created before slugs existed
+	if err := repository.EnsureSlugs(); err != nil {
+		log.Fatal("Failed to generate slugs:", err)
+	}
+
+	// storage keys for images uploaded before pluggable storage
+	if err := repository.MigrateImageKeys(); err != nil {
+		log.Fatal("Failed to migrate image keys:", err)
+	}
+
+	// fold legacy thumbnail/original image pairs into images with renditions
+	if err := repository.MigrateImageRenditions(); err != nil {
+		log.Fatal("Failed to migrate image renditions:", err)
+	}
+
+	// positions and covers for images uploaded before explicit ordering
+	if err := repository.EnsureImageOrder(); err != nil {
+		log.Fatal("Failed to order product images:", err)
+	}
+
+	// re-render images missing one of the configured renditions
+	if n, err := repository.QueueMissingRenditions(); err != nil {
+		log.Fatal("Failed to queue image renditions:", err)
+	} else if n > 0 {
+		log.Printf("Queued renditions for %d image(s)", n)
+	}
+
+	// one open order per subscription occurrence
+	if err := repository.EnsureSubscriptionOccurrences(); err != nil {
+		log.Fatal("Failed to index subscription orders:", err)
+	}
+
+	// carry over payment flags from before order statuses existed
+	if err := repository.MigrateLegacyPaidFlag(); err != nil {
+		log.Fatal("Failed to migrate order statuses:", err)
+	}
+
+	// price snapshots for orders placed before orders kept them
+	if err := repository.MigrateOrderTotals(); err != nil {
+		log.Fatal("Failed to migrate order totals:", err)
+	}
+
+	// bootstrap the first owner account from the environment
+	if err := repository.EnsureOwner(os.Getenv("ADMIN_USERNAME"), os.Getenv("ADMIN_PASSWORD")); err != nil {
+		log.Fatal("Failed to create owner account:", err)
+	}
+
+	// OTP codes go to a log file until a real SMS gateway is configured
+	utils.SMS = utils.LogSMSSender{Path: os.Getenv("SMS_LOG_FILE")}
+
+	// autocomplete index, rebuilt in the background after catalog edits
+	if err := repository.RefreshSuggestions(); err != nil {
+		log.Println("Failed to build search suggestions:", err)
+	}
+	go workers.RunSuggestionRefresher()
+
+	// generate image renditions in the background, IMAGE_WORKERS at a time
+	workers.RunImageWorkers(imageWorkers())
+
+	// materialize a week of upcoming subscription orders, checking hourly
+	go workers.RunSubscriptionGenerator(time.Hour, 7*24*time.Hour)
+
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 	// routes
 	router.Setup(r)
 
 	r.Run() // :8080 by default
 }
+
+// imageWorkers reads IMAGE_WORKERS, the number of images processed at once.
+func imageWorkers() int {
+	n, err := strconv.Atoi(os.Getenv("IMAGE_WORKERS"))
+	if err != nil || n < 1 {
+		return 2
+	}
+	return n
+}
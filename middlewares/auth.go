@@ -0,0 +1,81 @@
+package middlewares
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"bogbon-api/models"
+	"bogbon-api/repository"
+	"bogbon-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireAdmin rejects requests without a valid admin bearer token.
+// When roles are given, the admin must hold one of them; owners are always allowed.
+// The role is read from the account, not the token, so deleting or demoting
+// an admin takes effect right away.
+func RequireAdmin(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		token, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok || token == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "authorization required"})
+			return
+		}
+
+		claims, err := utils.ParseToken(token)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+
+		admin, err := repository.GetAdmin(claims.Subject)
+		if errors.Is(err, repository.ErrAdminNotFound) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "account no longer exists"})
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		if !roleAllowed(admin.Role, roles) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "insufficient permissions"})
+			return
+		}
+
+		c.Set("admin_id", admin.ID)
+		c.Set("admin_role", admin.Role)
+		c.Next()
+	}
+}
+
+func roleAllowed(role string, allowed []string) bool {
+	if role == models.RoleOwner {
+		return true
+	}
+	if len(allowed) == 0 {
+		return role == models.RoleCatalogEditor || role == models.RoleOrderManager
+	}
+	for _, r := range allowed {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireCustomer rejects requests whose session is not logged in to a customer account.
+func RequireCustomer() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := utils.GetCustomerID(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "login required"})
+			return
+		}
+		c.Set("customer_id", id)
+		c.Next()
+	}
+}
@@ -1,15 +1,21 @@
 package models
 
 import (
+	"bogbon-api/search"
+	"bogbon-api/storage"
 	"gorm.io/gorm"
+	"strings"
 	"time"
 )
 
-// Category: fixed taxonomy
+// Category: taxonomy node; categories nest to any depth via ParentID
 type Category struct {
 	ID           uint                  `gorm:"primaryKey;autoIncrement"`
+	ParentID     *uint                 `gorm:"index"`              // nil for top-level categories
+	Position     int                   `gorm:"not null;default:0"` // order among siblings
 	Translations []CategoryTranslation `gorm:"foreignKey:CategoryID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
 	Products     []Product             `gorm:"many2many:category_products;constraint:OnDelete:CASCADE;"`
+	Children     []Category            `gorm:"foreignKey:ParentID" json:",omitempty"`
 	CreatedAt    time.Time
 	UpdatedAt    time.Time
 }
@@ -19,8 +25,22 @@ type CategoryTranslation struct {
 	CategoryID   uint   `gorm:"not null"`
 	LanguageCode string `gorm:"size:10;not null"` // e.g., "en", "es"
 	Name         string `gorm:"not null"`
+	Slug         string `gorm:"size:100;not null;default:''"`            // unique per language
+	SearchText   string `gorm:"type:text" json:"-" swaggerignore:"true"` // normalized name, see search.Normalize
 }
 
+// BeforeSave keeps SearchText in sync with the name
+func (t *CategoryTranslation) BeforeSave(tx *gorm.DB) error {
+	t.SearchText = search.Normalize(t.Name)
+	return nil
+}
+
+// Product types
+const (
+	ProductTypePlant   = "plant"
+	ProductTypeService = "service"
+)
+
 // Product: can be "plant" or "service" determined by Type
 type Product struct {
 	ID           uint                 `gorm:"primaryKey;autoIncrement"`
@@ -30,6 +50,8 @@ type Product struct {
 	Categories   []Category           `gorm:"many2many:category_products;"`
 	Translations []ProductTranslation `gorm:"foreignKey:ProductID"`
 	Images       []ProductImage       `gorm:"foreignKey:ProductID;constraint:OnDelete:CASCADE;"`
+	Variants     []ProductVariant     `gorm:"foreignKey:ProductID;constraint:OnDelete:CASCADE;"`
+	Attributes   []ProductAttribute   `gorm:"foreignKey:ProductID;constraint:OnDelete:CASCADE;"`
 	CreatedAt    time.Time
 	UpdatedAt    time.Time
 	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-" swaggerignore:"true"`
@@ -43,15 +65,212 @@ type ProductTranslation struct {
 	Name         string `gorm:"not null"`
 	Description  string
 	ShortInfo    string
+	Slug         string `gorm:"size:100;not null;default:''"`            // unique per language
+	SearchText   string `gorm:"type:text" json:"-" swaggerignore:"true"` // normalized name, short info and description
+}
+
+// BeforeSave keeps SearchText in sync with the translated fields
+func (t *ProductTranslation) BeforeSave(tx *gorm.DB) error {
+	t.SearchText = search.Normalize(t.Name + " " + t.ShortInfo + " " + t.Description)
+	return nil
+}
+
+// ProductVariant: one sellable version of a product (e.g. a ficus in a 20 cm
+// ceramic pot) with its own SKU, price and stock
+type ProductVariant struct {
+	ID        uint            `gorm:"primaryKey;autoIncrement"`
+	ProductID uint            `gorm:"index;not null"`
+	SKU       string          `gorm:"size:64;not null;uniqueIndex"`
+	Price     int             `gorm:"not null"`
+	Stock     int             `gorm:"not null"`
+	Options   []VariantOption `gorm:"foreignKey:VariantID;constraint:OnDelete:CASCADE;"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// VariantOption: one option value of a variant, e.g. size = 20 cm
+type VariantOption struct {
+	ID        uint   `gorm:"primaryKey;autoIncrement"`
+	VariantID uint   `gorm:"index;not null"`
+	Name      string `gorm:"size:50;not null"` // e.g. "size", "color", "container"
+	Value     string `gorm:"size:100;not null"`
+}
+
+// Label describes the variant by its options, e.g. "size: 20 cm, container: ceramic"
+func (v ProductVariant) Label() string {
+	parts := make([]string, len(v.Options))
+	for i, o := range v.Options {
+		parts[i] = o.Name + ": " + o.Value
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Attribute types
+const (
+	AttributeEnum    = "enum"    // one or more of the attribute's Values
+	AttributeNumber  = "number"  // e.g. mature height in cm
+	AttributeBoolean = "boolean" // e.g. pet-safe
+	AttributeText    = "text"
+)
+
+// AttributeDefinition: an admin-defined product attribute such as light
+// requirement or hardiness zone
+type AttributeDefinition struct {
+	ID           uint                   `gorm:"primaryKey;autoIncrement"`
+	Code         string                 `gorm:"size:50;not null;uniqueIndex"` // used in ?attr[code]= filters
+	Type         string                 `gorm:"type:VARCHAR(20);not null"`
+	Unit         string                 `gorm:"size:20"` // number attributes only, e.g. "cm"
+	Position     int                    `gorm:"not null;default:0"`
+	Translations []AttributeTranslation `gorm:"foreignKey:AttributeID;constraint:OnDelete:CASCADE;"`
+	Values       []AttributeValue       `gorm:"foreignKey:AttributeID;constraint:OnDelete:CASCADE;"`
+}
+
+// AttributeTranslation: attribute label in one language
+type AttributeTranslation struct {
+	ID           uint   `gorm:"primaryKey;autoIncrement"`
+	AttributeID  uint   `gorm:"index;not null"`
+	LanguageCode string `gorm:"size:10;not null"`
+	Label        string `gorm:"not null"`
+}
+
+// AttributeValue: one allowed value of an enum attribute, e.g. "bright_indirect"
+type AttributeValue struct {
+	ID           uint                        `gorm:"primaryKey;autoIncrement"`
+	AttributeID  uint                        `gorm:"not null;uniqueIndex:idx_attribute_value_code"`
+	Code         string                      `gorm:"size:50;not null;uniqueIndex:idx_attribute_value_code"`
+	Position     int                         `gorm:"not null;default:0"`
+	Translations []AttributeValueTranslation `gorm:"foreignKey:ValueID;constraint:OnDelete:CASCADE;"`
+}
+
+// AttributeValueTranslation: enum value label in one language
+type AttributeValueTranslation struct {
+	ID           uint   `gorm:"primaryKey;autoIncrement"`
+	ValueID      uint   `gorm:"index;not null"`
+	LanguageCode string `gorm:"size:10;not null"`
+	Label        string `gorm:"not null"`
+}
+
+// ProductAttribute: a product's value for one attribute. Enum attributes may
+// have several rows, one per chosen value; the other types have one.
+type ProductAttribute struct {
+	ID          uint  `gorm:"primaryKey;autoIncrement"`
+	ProductID   uint  `gorm:"index;not null"`
+	AttributeID uint  `gorm:"index;not null"`
+	ValueID     *uint `gorm:"index"`
+	Number      *float64
+	Boolean     *bool
+	Text        string
+	Attribute   AttributeDefinition `gorm:"constraint:OnDelete:CASCADE;"`
+	Value       *AttributeValue     `gorm:"foreignKey:ValueID;constraint:OnDelete:CASCADE;"`
 }
 
-// image
+// Slug owners
+const (
+	SlugProduct  = "product"
+	SlugCategory = "category"
+)
+
+// SlugRedirect: a former slug that still resolves to its product or category
+type SlugRedirect struct {
+	ID           uint   `gorm:"primaryKey;autoIncrement"`
+	Kind         string `gorm:"type:VARCHAR(20);not null;uniqueIndex:idx_slug_redirect"`
+	LanguageCode string `gorm:"size:10;not null;uniqueIndex:idx_slug_redirect"`
+	Slug         string `gorm:"size:100;not null;uniqueIndex:idx_slug_redirect"`
+	TargetID     uint   `gorm:"not null;index"`
+	CreatedAt    time.Time
+}
+
+// image: one product photo. URL is the uploaded original; Renditions are
+// its resized copies. Images are shown by Position; the primary one is the
+// product's cover.
 type ProductImage struct {
+	ID           uint               `gorm:"primaryKey;autoIncrement"`
+	ProductID    uint               `gorm:"not null;index"`
+	URL          string             `gorm:"size:255;not null"`
+	StorageKey   string             `gorm:"size:255"` // where storage.Files keeps the file
+	IsOriginal   bool               `gorm:"not null"` // false only for legacy thumbnails without an original
+	Position     int                `gorm:"not null;default:0"`
+	IsPrimary    bool               `gorm:"not null;default:false"`
+	Renditions   []ImageRendition   `gorm:"foreignKey:ImageID;constraint:OnDelete:CASCADE;"`
+	Translations []ImageTranslation `gorm:"foreignKey:ImageID;constraint:OnDelete:CASCADE;"`
+	CreatedAt    time.Time
+}
+
+// AfterFind builds the URL from the storage key, so images follow a change
+// of storage driver, bucket or CDN instead of keeping the URL of upload time.
+// Legacy images without a key keep their stored URL.
+func (img *ProductImage) AfterFind(tx *gorm.DB) error {
+	if img.StorageKey != "" {
+		img.URL = storage.Files.URL(img.StorageKey)
+	}
+	return nil
+}
+
+// ImageTranslation: alt text and caption of a product image in one language
+type ImageTranslation struct {
+	ID           uint   `gorm:"primaryKey;autoIncrement"`
+	ImageID      uint   `gorm:"not null;uniqueIndex:idx_image_translation_lang"`
+	LanguageCode string `gorm:"size:10;not null;uniqueIndex:idx_image_translation_lang"`
+	Alt          string `gorm:"size:255"`
+	Caption      string `gorm:"type:text"`
+}
+
+// RenditionURL returns the URL of the named rendition, or the original's
+// when the image has no such rendition.
+func (img ProductImage) RenditionURL(name string) string {
+	for _, r := range img.Renditions {
+		if r.Name == name {
+			return r.URL
+		}
+	}
+	return img.URL
+}
+
+// Image job statuses
+const (
+	ImageJobPending    = "pending"
+	ImageJobProcessing = "processing"
+	ImageJobDone       = "done"
+	ImageJobFailed     = "failed"
+)
+
+// ImageJob: an uploaded product image waiting for its renditions. Workers
+// pick pending jobs once RunAt has passed; failures are retried with backoff.
+type ImageJob struct {
+	ID        uint          `gorm:"primaryKey;autoIncrement"`
+	ProductID uint          `gorm:"not null;index"`
+	Filename  string        `gorm:"size:255;not null"`
+	SourceKey string        `gorm:"size:255;not null"` // the uploaded original in storage.Files
+	Status    string        `gorm:"type:VARCHAR(20);not null;default:'pending';index"`
+	Attempts  int           `gorm:"not null;default:0"`
+	Error     string        `gorm:"type:text"` // last failure
+	RunAt     time.Time     `gorm:"not null"`
+	LockedAt  *time.Time    `json:"-"` // when a worker claimed the job
+	ImageID   *uint         // the created image, once done; the image to re-render for backfills
+	Backfill  bool          `gorm:"not null;default:false"` // re-renders an existing image instead of adding one
+	Image     *ProductImage `gorm:"constraint:OnDelete:SET NULL;"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// ImageRendition: one resized copy of a product image, e.g. its "card" size
+type ImageRendition struct {
 	ID         uint   `gorm:"primaryKey;autoIncrement"`
-	ProductID  uint   `gorm:"not null;index"`
+	ImageID    uint   `gorm:"not null;uniqueIndex:idx_image_rendition_name"`
+	Name       string `gorm:"size:30;not null;uniqueIndex:idx_image_rendition_name"`
+	Width      int
+	Height     int
 	URL        string `gorm:"size:255;not null"`
-	IsOriginal bool   `gorm:"not null"`
-	CreatedAt  time.Time
+	StorageKey string `gorm:"size:255"`
+	Spec       string `gorm:"size:60" json:"-"` // the settings it was rendered with, see images.Rendition.Spec
+}
+
+// AfterFind builds the URL from the storage key, like ProductImage.AfterFind.
+func (r *ImageRendition) AfterFind(tx *gorm.DB) error {
+	if r.StorageKey != "" {
+		r.URL = storage.Files.URL(r.StorageKey)
+	}
+	return nil
 }
 
 // Cart model: holds the cart items before checkout
@@ -65,28 +284,267 @@ type Cart struct {
 
 // CartItem model: now belongs to a Cart (not directly to SessionID or OrderID)
 type CartItem struct {
-	ID        uint `gorm:"primaryKey;autoIncrement"`
-	CartID    uint `gorm:"index;not null"`
-	ProductID uint `gorm:"not null"`
-	Quantity  int  `gorm:"not null;default:1"`
+	ID        uint       `gorm:"primaryKey;autoIncrement"`
+	CartID    uint       `gorm:"index;not null"`
+	ProductID uint       `gorm:"not null"`
+	Quantity  int        `gorm:"not null;default:1"`
+	VariantID *uint      `gorm:"index"`
+	SlotStart *time.Time // booking slot for service products
 	Product   Product
+	Variant   *ProductVariant
+}
+
+// Order statuses
+const (
+	OrderPending    = "pending"
+	OrderConfirmed  = "confirmed"
+	OrderPaid       = "paid"
+	OrderScheduled  = "scheduled"
+	OrderInProgress = "in_progress"
+	OrderDelivered  = "delivered"
+	OrderCompleted  = "completed"
+	OrderCancelled  = "cancelled"
+	OrderRefunded   = "refunded"
+)
+
+// OrderTransitions lists the statuses an order may move to from each status
+var OrderTransitions = map[string][]string{
+	OrderPending:    {OrderConfirmed, OrderCancelled},
+	OrderConfirmed:  {OrderPaid, OrderScheduled, OrderCancelled},
+	OrderPaid:       {OrderScheduled, OrderInProgress, OrderDelivered, OrderRefunded},
+	OrderScheduled:  {OrderPaid, OrderInProgress, OrderCancelled, OrderRefunded},
+	OrderInProgress: {OrderDelivered, OrderCompleted},
+	OrderDelivered:  {OrderCompleted, OrderRefunded},
+	OrderCompleted:  {OrderRefunded},
+}
+
+// CanTransition reports whether an order may move from one status to another
+func CanTransition(from, to string) bool {
+	for _, s := range OrderTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Preferred contact methods
+const (
+	ContactPhone    = "phone"
+	ContactSMS      = "sms"
+	ContactTelegram = "telegram"
+	ContactWhatsApp = "whatsapp"
+)
+
+// DeliveryDetails: who to contact and where to deliver, captured at checkout
+type DeliveryDetails struct {
+	CustomerName  string `gorm:"size:100"`
+	Phone         string `gorm:"size:20"`
+	Region        string `gorm:"size:100"`
+	District      string `gorm:"size:100"`
+	Street        string `gorm:"size:255"`
+	Latitude      *float64
+	Longitude     *float64
+	ContactMethod string `gorm:"type:VARCHAR(20)"`
+	Notes         string `gorm:"type:text"`
 }
 
 // Order model: created from a Cart
 type Order struct {
+	ID             uint       `gorm:"primaryKey;autoIncrement"`
+	SessionID      string     `gorm:"index;not null"`
+	CustomerID     *uint      `gorm:"index"`
+	CartID         uint       `gorm:"not null"`
+	Status         string     `gorm:"type:VARCHAR(20);not null;default:'pending';index"`
+	Subtotal       int        `gorm:"not null;default:0"`
+	Total          int        `gorm:"not null;default:0"`
+	SubscriptionID *uint      `gorm:"index"`
+	ScheduledFor   *time.Time // occurrence date of a subscription order; one open order each, see repository.EnsureSubscriptionOccurrences
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	Items          []OrderItem         `gorm:"foreignKey:OrderID"`
+	StatusHistory  []OrderStatusChange `gorm:"foreignKey:OrderID;constraint:OnDelete:CASCADE;"`
+	DeliveryDetails
+}
+
+// OrderStatusChange model: one recorded transition of an order's status
+type OrderStatusChange struct {
+	ID         uint   `gorm:"primaryKey;autoIncrement"`
+	OrderID    uint   `gorm:"index;not null"`
+	FromStatus string `gorm:"type:VARCHAR(20)"`
+	ToStatus   string `gorm:"type:VARCHAR(20);not null"`
+	AdminID    *uint
+	Note       string
+	CreatedAt  time.Time
+}
+
+// OrderItem model: copies data from CartItems into Order, snapshotting
+// price, type and names so later catalog edits don't rewrite history
+type OrderItem struct {
+	ID           uint `gorm:"primaryKey;autoIncrement"`
+	OrderID      uint `gorm:"index;not null"`
+	ProductID    uint `gorm:"not null"`
+	VariantID    *uint
+	SKU          string `gorm:"size:64"`
+	VariantLabel string // variant options at checkout
+	Quantity     int    `gorm:"not null"`
+	UnitPrice    int    `gorm:"not null;default:0"`
+	LineTotal    int    `gorm:"not null;default:0"`
+	ProductType  string `gorm:"type:VARCHAR(20)"`
+	SlotStart    *time.Time
+	SlotEnd      *time.Time
+	Translations []OrderItemTranslation `gorm:"foreignKey:OrderItemID;constraint:OnDelete:CASCADE;"`
+	Product      Product
+}
+
+// OrderItemTranslation: product name as it was at checkout, per language
+type OrderItemTranslation struct {
+	ID           uint   `gorm:"primaryKey;autoIncrement"`
+	OrderItemID  uint   `gorm:"index;not null"`
+	LanguageCode string `gorm:"size:10;not null"`
+	Name         string `gorm:"not null"`
+}
+
+// Admin roles
+const (
+	RoleOwner         = "owner"
+	RoleCatalogEditor = "catalog_editor"
+	RoleOrderManager  = "order_manager"
+)
+
+// Admin model: staff account that manages the catalog and orders
+type Admin struct {
+	ID           uint   `gorm:"primaryKey;autoIncrement"`
+	Username     string `gorm:"size:64;not null;uniqueIndex"`
+	PasswordHash string `gorm:"not null" json:"-"`
+	Role         string `gorm:"type:VARCHAR(20);not null"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// Customer model: registered shopper; SessionID is the stable key their cart and orders live under
+type Customer struct {
+	ID           uint    `gorm:"primaryKey;autoIncrement"`
+	Name         string  `gorm:"size:100"`
+	Phone        *string `gorm:"size:20;uniqueIndex"`
+	Email        *string `gorm:"size:255;uniqueIndex"`
+	PasswordHash string  `json:"-"`
+	SessionID    string  `gorm:"not null;uniqueIndex" json:"-"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// OTPCode model: one-time login code sent to a phone number
+type OTPCode struct {
+	ID         uint   `gorm:"primaryKey;autoIncrement"`
+	Phone      string `gorm:"size:20;index;not null"`
+	CodeHash   string `gorm:"size:64;not null"`
+	Attempts   int    `gorm:"not null;default:0"`
+	ExpiresAt  time.Time
+	ConsumedAt *time.Time
+	CreatedAt  time.Time
+}
+
+// ServiceSchedule model: working hours and slot capacity of a service product on one weekday
+type ServiceSchedule struct {
+	ID          uint   `gorm:"primaryKey;autoIncrement"`
+	ProductID   uint   `gorm:"index;not null"`
+	Weekday     int    `gorm:"not null"`        // 0 = Sunday ... 6 = Saturday
+	StartTime   string `gorm:"size:5;not null"` // "09:00", business time zone
+	EndTime     string `gorm:"size:5;not null"` // "18:00"
+	SlotMinutes int    `gorm:"not null"`
+	Capacity    int    `gorm:"not null;default:1"`
+}
+
+// SlotReservation model: a booked slot held by an order item
+type SlotReservation struct {
+	ID          uint      `gorm:"primaryKey;autoIncrement"`
+	ProductID   uint      `gorm:"not null;index:idx_slot_reservation,priority:1"`
+	StartsAt    time.Time `gorm:"not null;index:idx_slot_reservation,priority:2"`
+	EndsAt      time.Time `gorm:"not null"`
+	OrderID     uint      `gorm:"index;not null"`
+	OrderItemID uint      `gorm:"not null"`
+	Quantity    int       `gorm:"not null;default:1"` // places taken in the slot
+	CreatedAt   time.Time
+}
+
+// Crew model: a team of gardeners dispatched to service orders
+type Crew struct {
+	ID        uint     `gorm:"primaryKey;autoIncrement"`
+	Name      string   `gorm:"size:100;not null"`
+	Active    bool     `gorm:"not null;default:true"`
+	Workers   []Worker `gorm:"foreignKey:CrewID"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Worker model: a gardener, optionally a member of a crew
+type Worker struct {
 	ID        uint   `gorm:"primaryKey;autoIncrement"`
-	SessionID string `gorm:"index;not null"`
-	CartID    uint   `gorm:"not null"`
-	IsPaid    bool   `gorm:"default:false"`
+	CrewID    *uint  `gorm:"index"`
+	Name      string `gorm:"size:100;not null"`
+	Phone     string `gorm:"size:20"`
+	Active    bool   `gorm:"not null;default:true"`
 	CreatedAt time.Time
-	Items     []OrderItem `gorm:"foreignKey:OrderID"`
+	UpdatedAt time.Time
 }
 
-// OrderItem model: copies data from CartItems into Order
-type OrderItem struct {
-	ID        uint `gorm:"primaryKey;autoIncrement"`
-	OrderID   uint `gorm:"index;not null"`
-	ProductID uint `gorm:"not null"`
-	Quantity  int  `gorm:"not null"`
-	Product   Product
+// CrewAssignment model: a service order item dispatched to a crew for a time window
+type CrewAssignment struct {
+	ID          uint      `gorm:"primaryKey;autoIncrement"`
+	CrewID      uint      `gorm:"not null;index:idx_crew_assignment_window,priority:1"`
+	OrderID     uint      `gorm:"not null;index"`
+	OrderItemID uint      `gorm:"not null;uniqueIndex"`
+	StartsAt    time.Time `gorm:"not null;index:idx_crew_assignment_window,priority:2"`
+	EndsAt      time.Time `gorm:"not null"`
+	Note        string
+	Order       Order
+	OrderItem   OrderItem
+	CreatedAt   time.Time
+}
+
+// Subscription frequencies and statuses
+const (
+	FrequencyWeekly   = "weekly"
+	FrequencyBiweekly = "biweekly"
+	FrequencyMonthly  = "monthly"
+
+	SubscriptionActive    = "active"
+	SubscriptionPaused    = "paused"
+	SubscriptionCancelled = "cancelled"
+)
+
+// Subscription model: a recurring service order, materialized ahead of time by a background generator
+type Subscription struct {
+	ID         uint               `gorm:"primaryKey;autoIncrement"`
+	CustomerID uint               `gorm:"index;not null"`
+	ProductID  uint               `gorm:"not null"`
+	Quantity   int                `gorm:"not null;default:1"`
+	Frequency  string             `gorm:"type:VARCHAR(20);not null"`
+	Weekday    int                `gorm:"not null"` // 0 = Sunday ... 6 = Saturday
+	TimeOfDay  string             `gorm:"size:5"`   // preferred slot start "HH:MM", optional
+	StartDate  time.Time          `gorm:"not null"` // first occurrence
+	NextDate   time.Time          `gorm:"not null;index"`
+	Status     string             `gorm:"type:VARCHAR(20);not null;default:'active';index"`
+	Skips      []SubscriptionSkip `gorm:"foreignKey:SubscriptionID;constraint:OnDelete:CASCADE;"`
+	Product    Product
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	DeliveryDetails
+}
+
+// SubscriptionSkip: an occurrence date the customer opted out of
+type SubscriptionSkip struct {
+	ID             uint      `gorm:"primaryKey;autoIncrement"`
+	SubscriptionID uint      `gorm:"not null;uniqueIndex:idx_subscription_skip"`
+	Date           time.Time `gorm:"not null;uniqueIndex:idx_subscription_skip"`
+}
+
+// SearchSynonym: admin-managed pair of normalized terms that should find each other,
+// e.g. "roza" <-> "atirgul"
+type SearchSynonym struct {
+	ID        uint   `gorm:"primaryKey;autoIncrement"`
+	Term      string `gorm:"size:100;not null;uniqueIndex:idx_search_synonym"`
+	Synonym   string `gorm:"size:100;not null;uniqueIndex:idx_search_synonym;index"`
+	CreatedAt time.Time
 }
@@ -0,0 +1,61 @@
+package models
+
+import "testing"
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{OrderPending, OrderConfirmed, true},
+		{OrderPending, OrderCancelled, true},
+		{OrderPending, OrderPaid, false},
+		{OrderPending, OrderPending, false},
+		{OrderConfirmed, OrderPaid, true},
+		{OrderConfirmed, OrderRefunded, false},
+		{OrderPaid, OrderRefunded, true},
+		{OrderPaid, OrderCancelled, false},
+		{OrderScheduled, OrderCancelled, true},
+		{OrderInProgress, OrderCancelled, false},
+		{OrderDelivered, OrderCompleted, true},
+		{OrderCompleted, OrderRefunded, true},
+		{OrderCompleted, OrderPending, false},
+		{OrderCancelled, OrderPending, false},
+		{OrderRefunded, OrderCompleted, false},
+		{"unknown", OrderCancelled, false},
+		{OrderPending, "unknown", false},
+	}
+	for _, tt := range tests {
+		if got := CanTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanTransition(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestOrderTransitions(t *testing.T) {
+	known := map[string]bool{
+		OrderPending: true, OrderConfirmed: true, OrderPaid: true, OrderScheduled: true,
+		OrderInProgress: true, OrderDelivered: true, OrderCompleted: true,
+		OrderCancelled: true, OrderRefunded: true,
+	}
+	for from, targets := range OrderTransitions {
+		if !known[from] {
+			t.Errorf("transitions from unknown status %q", from)
+		}
+		for _, to := range targets {
+			if !known[to] {
+				t.Errorf("%s -> unknown status %q", from, to)
+			}
+			if to == from {
+				t.Errorf("%s -> itself", from)
+			}
+		}
+	}
+
+	// Cancelled and refunded orders are final
+	for _, final := range []string{OrderCancelled, OrderRefunded} {
+		if len(OrderTransitions[final]) != 0 {
+			t.Errorf("%s has transitions %v, want none", final, OrderTransitions[final])
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"bogbon-api/config"
+	"bogbon-api/models"
+	"errors"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrAdminNotFound      = errors.New("admin not found")
+	ErrUsernameTaken      = errors.New("username is already in use")
+)
+
+// CreateAdmin hashes the password and stores a new admin account.
+func CreateAdmin(username, password, role string) (*models.Admin, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	admin := models.Admin{
+		Username:     username,
+		PasswordHash: string(hash),
+		Role:         role,
+	}
+	var taken int64
+	if err := config.DB.Model(&models.Admin{}).Where("username = ?", username).Count(&taken).Error; err != nil {
+		return nil, err
+	}
+	if taken > 0 {
+		return nil, ErrUsernameTaken
+	}
+	if err := config.DB.Create(&admin).Error; err != nil {
+		return nil, err
+	}
+	return &admin, nil
+}
+
+// GetAdmin returns an admin account by ID.
+func GetAdmin(id uint) (*models.Admin, error) {
+	var admin models.Admin
+	err := config.DB.First(&admin, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrAdminNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &admin, nil
+}
+
+// AuthenticateAdmin checks the credentials and returns the matching admin.
+func AuthenticateAdmin(username, password string) (*models.Admin, error) {
+	var admin models.Admin
+	err := config.DB.Where("username = ?", username).First(&admin).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(admin.PasswordHash), []byte(password)) != nil {
+		return nil, ErrInvalidCredentials
+	}
+	return &admin, nil
+}
+
+// GetAllAdmins returns every admin account.
+func GetAllAdmins() ([]models.Admin, error) {
+	var admins []models.Admin
+	err := config.DB.Order("id").Find(&admins).Error
+	return admins, err
+}
+
+// DeleteAdmin removes an admin account by ID.
+func DeleteAdmin(id uint) error {
+	return config.DB.Delete(&models.Admin{}, id).Error
+}
+
+// EnsureOwner creates the initial owner account when no admins exist yet.
+func EnsureOwner(username, password string) error {
+	var count int64
+	if err := config.DB.Model(&models.Admin{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 || username == "" || password == "" {
+		return nil
+	}
+	_, err := CreateAdmin(username, password, models.RoleOwner)
+	return err
+}
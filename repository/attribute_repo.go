@@ -0,0 +1,417 @@
+package repository
+
+import (
+	"bogbon-api/config"
+	"bogbon-api/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAttributeNotFound   = errors.New("attribute not found")
+	ErrAttributeCodeTaken  = errors.New("attribute code is already in use")
+	ErrAttributeType       = errors.New("attribute type must be enum, number, boolean or text")
+	ErrAttributeTypeChange = errors.New("the type of an attribute cannot be changed")
+)
+
+// AttributeValueError reports a product attribute value or filter that does
+// not fit its attribute's definition.
+type AttributeValueError struct {
+	Code   string
+	Reason string
+}
+
+func (e *AttributeValueError) Error() string {
+	return fmt.Sprintf("attribute %s: %s", e.Code, e.Reason)
+}
+
+// ProductAttributeInput is one attribute value to set on a product. Value is
+// a string code (or list of codes) for enums, a number, a boolean or a string.
+type ProductAttributeInput struct {
+	Code  string          `json:"code" binding:"required"`
+	Value json.RawMessage `json:"value" binding:"required"`
+}
+
+// GetAllAttributes returns every attribute definition with its labels and enum values.
+func GetAllAttributes() ([]models.AttributeDefinition, error) {
+	var attrs []models.AttributeDefinition
+	err := config.DB.Scopes(withAttributeDetails).Order("position, id").Find(&attrs).Error
+	return attrs, err
+}
+
+func withAttributeDetails(db *gorm.DB) *gorm.DB {
+	return db.Preload("Translations").
+		Preload("Values", func(db *gorm.DB) *gorm.DB { return db.Order("position, id") }).
+		Preload("Values.Translations")
+}
+
+// CreateAttribute stores a new attribute definition with its labels and values.
+func CreateAttribute(attr *models.AttributeDefinition) error {
+	if !validAttributeType(attr.Type) {
+		return ErrAttributeType
+	}
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		var taken int64
+		if err := tx.Model(&models.AttributeDefinition{}).Where("code = ?", attr.Code).Count(&taken).Error; err != nil {
+			return err
+		}
+		if taken > 0 {
+			return ErrAttributeCodeTaken
+		}
+		if attr.Type != models.AttributeEnum {
+			attr.Values = nil
+		}
+		if err := tx.Create(attr).Error; err != nil {
+			return err
+		}
+		return tx.Scopes(withAttributeDetails).First(attr, attr.ID).Error
+	})
+}
+
+// UpdateAttribute replaces an attribute's unit, position and labels. Enum
+// values are matched by code: existing ones keep their ID (and the products
+// using them), new ones are added, and missing ones are removed from the
+// attribute and from every product.
+func UpdateAttribute(attr *models.AttributeDefinition) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		var current models.AttributeDefinition
+		err := tx.Preload("Values").First(&current, attr.ID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrAttributeNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if attr.Type != "" && attr.Type != current.Type {
+			return ErrAttributeTypeChange
+		}
+		if attr.Code != current.Code {
+			var taken int64
+			if err := tx.Model(&models.AttributeDefinition{}).Where("code = ? AND id <> ?", attr.Code, attr.ID).
+				Count(&taken).Error; err != nil {
+				return err
+			}
+			if taken > 0 {
+				return ErrAttributeCodeTaken
+			}
+		}
+
+		if err := tx.Model(&current).Select("code", "unit", "position").Updates(attr).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("attribute_id = ?", attr.ID).Delete(&models.AttributeTranslation{}).Error; err != nil {
+			return err
+		}
+		for i := range attr.Translations {
+			attr.Translations[i].ID = 0
+			attr.Translations[i].AttributeID = attr.ID
+		}
+		if len(attr.Translations) > 0 {
+			if err := tx.Create(&attr.Translations).Error; err != nil {
+				return err
+			}
+		}
+
+		if current.Type == models.AttributeEnum {
+			if err := syncAttributeValues(tx, current.Values, attr.ID, attr.Values); err != nil {
+				return err
+			}
+		}
+		return tx.Scopes(withAttributeDetails).First(attr, attr.ID).Error
+	})
+}
+
+func syncAttributeValues(tx *gorm.DB, current []models.AttributeValue, attributeID uint, wanted []models.AttributeValue) error {
+	byCode := make(map[string]uint, len(current))
+	for _, v := range current {
+		byCode[v.Code] = v.ID
+	}
+
+	keep := make(map[uint]bool)
+	for _, v := range wanted {
+		translations := v.Translations
+		if id, ok := byCode[v.Code]; ok {
+			keep[id] = true
+			if err := tx.Model(&models.AttributeValue{}).Where("id = ?", id).
+				Update("position", v.Position).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("value_id = ?", id).Delete(&models.AttributeValueTranslation{}).Error; err != nil {
+				return err
+			}
+			for i := range translations {
+				translations[i].ID = 0
+				translations[i].ValueID = id
+			}
+			if len(translations) > 0 {
+				if err := tx.Create(&translations).Error; err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		v.ID = 0
+		v.AttributeID = attributeID
+		if err := tx.Create(&v).Error; err != nil {
+			return err
+		}
+	}
+
+	for _, v := range current {
+		if keep[v.ID] {
+			continue
+		}
+		if err := tx.Where("value_id = ?", v.ID).Delete(&models.ProductAttribute{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&models.AttributeValue{}, v.ID).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteAttribute removes an attribute definition and every product's value for it.
+func DeleteAttribute(id uint) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("attribute_id = ?", id).Delete(&models.ProductAttribute{}).Error; err != nil {
+			return err
+		}
+		res := tx.Delete(&models.AttributeDefinition{}, id)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrAttributeNotFound
+		}
+		return nil
+	})
+}
+
+// SetProductAttributes replaces all attribute values of a product.
+func SetProductAttributes(productID uint, inputs []ProductAttributeInput) ([]models.ProductAttribute, error) {
+	var rows []models.ProductAttribute
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.First(&models.Product{}, productID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrProductNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		defs, err := attributesByCode(tx, inputCodes(inputs))
+		if err != nil {
+			return err
+		}
+		for _, in := range inputs {
+			parsed, err := productAttributeRows(defs[in.Code], in)
+			if err != nil {
+				return err
+			}
+			for i := range parsed {
+				parsed[i].ProductID = productID
+			}
+			rows = append(rows, parsed...)
+		}
+
+		if err := tx.Where("product_id = ?", productID).Delete(&models.ProductAttribute{}).Error; err != nil {
+			return err
+		}
+		if len(rows) > 0 {
+			if err := tx.Omit("Attribute", "Value").Create(&rows).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = config.DB.Scopes(withProductAttributes).
+		Where("product_id = ?", productID).Order("id").
+		Find(&rows).Error
+	return rows, err
+}
+
+// withProductAttributes preloads what a product attribute needs for display.
+func withProductAttributes(db *gorm.DB) *gorm.DB {
+	return db.Preload("Attribute.Translations").Preload("Value.Translations")
+}
+
+func inputCodes(inputs []ProductAttributeInput) []string {
+	codes := make([]string, len(inputs))
+	for i, in := range inputs {
+		codes[i] = in.Code
+	}
+	return codes
+}
+
+// attributesByCode loads the definitions of codes, failing on unknown ones.
+func attributesByCode(tx *gorm.DB, codes []string) (map[string]*models.AttributeDefinition, error) {
+	defs := make(map[string]*models.AttributeDefinition, len(codes))
+	if len(codes) == 0 {
+		return defs, nil
+	}
+	var found []models.AttributeDefinition
+	if err := tx.Preload("Values").Where("code IN ?", codes).Find(&found).Error; err != nil {
+		return nil, err
+	}
+	for i := range found {
+		defs[found[i].Code] = &found[i]
+	}
+	for _, code := range codes {
+		if defs[code] == nil {
+			return nil, &AttributeValueError{Code: code, Reason: "unknown attribute"}
+		}
+	}
+	return defs, nil
+}
+
+// productAttributeRows converts one input into rows for its attribute's type.
+func productAttributeRows(def *models.AttributeDefinition, in ProductAttributeInput) ([]models.ProductAttribute, error) {
+	invalid := func(reason string) error { return &AttributeValueError{Code: def.Code, Reason: reason} }
+	row := models.ProductAttribute{AttributeID: def.ID}
+
+	switch def.Type {
+	case models.AttributeEnum:
+		var codes []string
+		if err := json.Unmarshal(in.Value, &codes); err != nil {
+			var code string
+			if err := json.Unmarshal(in.Value, &code); err != nil {
+				return nil, invalid("expected a value code or a list of codes")
+			}
+			codes = []string{code}
+		}
+		var rows []models.ProductAttribute
+		for _, code := range codes {
+			id, ok := enumValueID(def, code)
+			if !ok {
+				return nil, invalid(fmt.Sprintf("unknown value %q", code))
+			}
+			r := row
+			r.ValueID = &id
+			rows = append(rows, r)
+		}
+		return rows, nil
+	case models.AttributeNumber:
+		var n float64
+		if err := json.Unmarshal(in.Value, &n); err != nil {
+			return nil, invalid("expected a number")
+		}
+		row.Number = &n
+	case models.AttributeBoolean:
+		var b bool
+		if err := json.Unmarshal(in.Value, &b); err != nil {
+			return nil, invalid("expected true or false")
+		}
+		row.Boolean = &b
+	default:
+		if err := json.Unmarshal(in.Value, &row.Text); err != nil {
+			return nil, invalid("expected a string")
+		}
+	}
+	return []models.ProductAttribute{row}, nil
+}
+
+func enumValueID(def *models.AttributeDefinition, code string) (uint, bool) {
+	for _, v := range def.Values {
+		if v.Code == code {
+			return v.ID, true
+		}
+	}
+	return 0, false
+}
+
+// attributeFilters turns ?attr[code]=value filters into conditions on
+// products. Enums take a comma-separated list of value codes (any matches),
+// numbers an exact value or a "min..max" range with either side optional,
+// booleans true/false and text an exact, case-insensitive string.
+func attributeFilters(filters map[string]string) ([]string, [][]interface{}, error) {
+	codes := make([]string, 0, len(filters))
+	for code := range filters {
+		codes = append(codes, code)
+	}
+	defs, err := attributesByCode(config.DB, codes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var conds []string
+	var vars [][]interface{}
+	for _, code := range codes {
+		def, raw := defs[code], strings.TrimSpace(filters[code])
+		invalid := &AttributeValueError{Code: code, Reason: "invalid filter value"}
+		const sub = "products.id IN (SELECT pa.product_id FROM product_attributes pa WHERE pa.attribute_id = ? AND "
+
+		switch def.Type {
+		case models.AttributeEnum:
+			conds = append(conds, sub+"pa.value_id IN (SELECT id FROM attribute_values WHERE attribute_id = ? AND code IN ?))")
+			vars = append(vars, []interface{}{def.ID, def.ID, strings.Split(raw, ",")})
+		case models.AttributeNumber:
+			min, max, ok := parseNumberRange(raw)
+			if !ok {
+				return nil, nil, invalid
+			}
+			cond, v := sub+"TRUE", []interface{}{def.ID}
+			if min != nil {
+				cond += " AND pa.number >= ?"
+				v = append(v, *min)
+			}
+			if max != nil {
+				cond += " AND pa.number <= ?"
+				v = append(v, *max)
+			}
+			conds = append(conds, cond+")")
+			vars = append(vars, v)
+		case models.AttributeBoolean:
+			b, err := strconv.ParseBool(raw)
+			if err != nil {
+				return nil, nil, invalid
+			}
+			conds = append(conds, sub+"pa.boolean = ?)")
+			vars = append(vars, []interface{}{def.ID, b})
+		default:
+			conds = append(conds, sub+"LOWER(pa.text) = LOWER(?))")
+			vars = append(vars, []interface{}{def.ID, raw})
+		}
+	}
+	return conds, vars, nil
+}
+
+// parseNumberRange parses "12", "10..50", "10.." or "..50".
+func parseNumberRange(raw string) (min, max *float64, ok bool) {
+	parse := func(s string) (*float64, bool) {
+		if s == "" {
+			return nil, true
+		}
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, false
+		}
+		return &n, true
+	}
+
+	lo, hi, isRange := strings.Cut(raw, "..")
+	if !isRange {
+		n, ok := parse(raw)
+		return n, n, ok && n != nil
+	}
+	min, okMin := parse(strings.TrimSpace(lo))
+	max, okMax := parse(strings.TrimSpace(hi))
+	return min, max, okMin && okMax && (min != nil || max != nil)
+}
+
+func validAttributeType(t string) bool {
+	switch t {
+	case models.AttributeEnum, models.AttributeNumber, models.AttributeBoolean, models.AttributeText:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"testing"
+
+	"bogbon-api/models"
+)
+
+func TestParseNumberRange(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+	tests := []struct {
+		in       string
+		min, max *float64
+		ok       bool
+	}{
+		{"12", f(12), f(12), true},
+		{"-2.5", f(-2.5), f(-2.5), true},
+		{"10..50", f(10), f(50), true},
+		{"10 .. 50", f(10), f(50), true},
+		{"10..", f(10), nil, true},
+		{"..50", nil, f(50), true},
+		{"..", nil, nil, false},
+		{"", nil, nil, false},
+		{"ten", nil, nil, false},
+		{"10..big", nil, nil, false},
+	}
+	eq := func(a, b *float64) bool { return (a == nil && b == nil) || (a != nil && b != nil && *a == *b) }
+	show := func(p *float64) interface{} {
+		if p == nil {
+			return nil
+		}
+		return *p
+	}
+	for _, tt := range tests {
+		min, max, ok := parseNumberRange(tt.in)
+		if ok != tt.ok || (ok && (!eq(min, tt.min) || !eq(max, tt.max))) {
+			t.Errorf("parseNumberRange(%q) = %v, %v, %v, want %v, %v, %v",
+				tt.in, show(min), show(max), ok, show(tt.min), show(tt.max), tt.ok)
+		}
+	}
+}
+
+func TestValidAttributeType(t *testing.T) {
+	for _, typ := range []string{models.AttributeEnum, models.AttributeNumber, models.AttributeBoolean, models.AttributeText} {
+		if !validAttributeType(typ) {
+			t.Errorf("validAttributeType(%q) = false", typ)
+		}
+	}
+	for _, typ := range []string{"", "color", "Number"} {
+		if validAttributeType(typ) {
+			t.Errorf("validAttributeType(%q) = true", typ)
+		}
+	}
+}
@@ -31,7 +31,7 @@ func AddCartItem(item *models.CartItem) error {
 // GetCart returns the cart (with items) for a session.
 func GetCart(sessionID string) (*models.Cart, error) {
 	var cart models.Cart
-	err := config.DB.Preload("Items.Product").
+	err := config.DB.Preload("Items.Product.Translations").Preload("Items.Variant.Options").
 		Where("session_id = ?", sessionID).
 		First(&cart).Error
 	if err != nil {
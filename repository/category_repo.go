@@ -3,11 +3,43 @@ package repository
 import (
 	"bogbon-api/config"
 	"bogbon-api/models"
+	"bogbon-api/search"
+	"errors"
+	"slices"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrCategoryNotFound = errors.New("category not found")
+	ErrParentNotFound   = errors.New("parent category not found")
+	ErrCategoryCycle    = errors.New("a category cannot be moved under itself or one of its descendants")
 )
 
+// descendantsSQL selects the IDs of the categories in ? and everything below
+// them. UNION drops repeats, so even a corrupted cycle terminates.
+const descendantsSQL = `WITH RECURSIVE sub AS (
+	SELECT id FROM categories WHERE id IN ?
+	UNION
+	SELECT c.id FROM categories c JOIN sub ON c.parent_id = sub.id
+) SELECT id FROM sub`
+
+// subtreeSQL pairs every category (root) with itself and each category below
+// it (id), to roll counts up the tree.
+const subtreeSQL = `WITH RECURSIVE sub AS (
+	SELECT id AS root, id FROM categories
+	UNION
+	SELECT sub.root, c.id FROM categories c JOIN sub ON c.parent_id = sub.id
+) SELECT root, id FROM sub`
+
 func CreateCategory(c *models.Category, translations map[string]struct {
 	Name string `json:"name"`
 }) (*models.Category, error) {
+	if err := checkParent(config.DB, c.ID, c.ParentID); err != nil {
+		return nil, err
+	}
+
 	// Create category
 	if err := config.DB.Create(c).Error; err != nil {
 		return nil, err
@@ -15,16 +47,23 @@ func CreateCategory(c *models.Category, translations map[string]struct {
 
 	// Create translations
 	for lang, trans := range translations {
+		slug, err := uniqueSlug(config.DB, models.SlugCategory, c.ID, lang, trans.Name)
+		if err != nil {
+			return nil, err
+		}
 		record := models.CategoryTranslation{
 			CategoryID:   c.ID,
 			LanguageCode: lang,
 			Name:         trans.Name,
+			Slug:         slug,
 		}
 		if err := config.DB.Create(&record).Error; err != nil {
 			return nil, err
 		}
 	}
 
+	search.Suggestions.Invalidate()
+
 	// Reload with translations
 	if err := config.DB.Preload("Translations").First(c, c.ID).Error; err != nil {
 		return nil, err
@@ -40,37 +79,181 @@ func GetAllCategories() ([]models.Category, error) {
 	return cats, err
 }
 
-// DeleteCategory removes a category by ID.
-func DeleteCategory(id uint) error {
-	return config.DB.Delete(&models.Category{}, id).Error
+// GetCategoryByID returns a category with its translations and direct children.
+func GetCategoryByID(id uint) (*models.Category, error) {
+	var c models.Category
+	err := config.DB.Preload("Translations").
+		Preload("Children", func(db *gorm.DB) *gorm.DB { return db.Order("position, id") }).
+		Preload("Children.Translations").
+		First(&c, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrCategoryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
 }
 
-// Update category
-func UpdateCategory(c *models.Category, translations map[string]struct {
-	Name string `json:"name"`
-}) error {
-	// Save the base category (not much to update unless you add more fields)
-	if err := config.DB.Save(c).Error; err != nil {
-		return err
+// GetCategoryTree returns the top-level categories with their descendants
+// nested in Children, siblings ordered by Position.
+func GetCategoryTree() ([]models.Category, error) {
+	var cats []models.Category
+	if err := config.DB.Preload("Translations").Order("position, id").Find(&cats).Error; err != nil {
+		return nil, err
 	}
 
-	// Remove existing translations
-	if err := config.DB.Where("category_id = ?", c.ID).Delete(&models.CategoryTranslation{}).Error; err != nil {
+	byParent := map[uint][]models.Category{} // 0 holds the roots
+	exists := make(map[uint]bool, len(cats))
+	for _, c := range cats {
+		exists[c.ID] = true
+	}
+	for _, c := range cats {
+		parent := uint(0)
+		if c.ParentID != nil && exists[*c.ParentID] {
+			parent = *c.ParentID
+		}
+		byParent[parent] = append(byParent[parent], c)
+	}
+
+	seen := map[uint]bool{}
+	var build func(parent uint) []models.Category
+	build = func(parent uint) []models.Category {
+		var children []models.Category
+		for _, c := range byParent[parent] {
+			if seen[c.ID] {
+				continue
+			}
+			seen[c.ID] = true
+			c.Children = build(c.ID)
+			children = append(children, c)
+		}
+		return children
+	}
+	return build(0), nil
+}
+
+// DeleteCategory removes a category by ID. Its children move up to its parent.
+func DeleteCategory(id uint) error {
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var c models.Category
+		if err := tx.First(&c, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrCategoryNotFound
+			}
+			return err
+		}
+		if err := tx.Model(&models.Category{}).Where("parent_id = ?", id).
+			Update("parent_id", c.ParentID).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("kind = ? AND target_id = ?", models.SlugCategory, id).
+			Delete(&models.SlugRedirect{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&c).Error
+	})
+	if err != nil {
 		return err
 	}
+	search.Suggestions.Invalidate()
+	return nil
+}
 
-	// Add new translations
-	for lang, trans := range translations {
-		record := models.CategoryTranslation{
-			CategoryID:   c.ID,
-			LanguageCode: lang,
-			Name:         trans.Name,
+// Update category. Only the base columns named in columns ("parent_id",
+// "position") are written, so a rename keeps the category's place in the tree.
+func UpdateCategory(c *models.Category, columns []string, translations map[string]struct {
+	Name string `json:"name"`
+}) error {
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.Category{}, c.ID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrCategoryNotFound
 		}
-		if err := config.DB.Create(&record).Error; err != nil {
+		if err != nil {
+			return err
+		}
+
+		// Save the base category's place in the tree
+		if slices.Contains(columns, "parent_id") {
+			if err := checkParent(tx, c.ID, c.ParentID); err != nil {
+				return err
+			}
+		}
+		if len(columns) > 0 {
+			if err := tx.Model(&models.Category{}).Where("id = ?", c.ID).
+				Select(columns).Updates(c).Error; err != nil {
+				return err
+			}
+		}
+
+		// Remember the current slugs so renamed categories keep their old URLs
+		oldSlugs, err := currentSlugs(tx, models.SlugCategory, c.ID)
+		if err != nil {
+			return err
+		}
+
+		// Remove existing translations
+		if err := tx.Where("category_id = ?", c.ID).Delete(&models.CategoryTranslation{}).Error; err != nil {
 			return err
 		}
+
+		// Add new translations
+		newSlugs := make(map[string]string, len(translations))
+		for lang, trans := range translations {
+			slug, err := uniqueSlug(tx, models.SlugCategory, c.ID, lang, trans.Name)
+			if err != nil {
+				return err
+			}
+			record := models.CategoryTranslation{
+				CategoryID:   c.ID,
+				LanguageCode: lang,
+				Name:         trans.Name,
+				Slug:         slug,
+			}
+			if err := tx.Create(&record).Error; err != nil {
+				return err
+			}
+			newSlugs[lang] = slug
+		}
+
+		return recordSlugChanges(tx, models.SlugCategory, c.ID, oldSlugs, newSlugs)
+	})
+	if err != nil {
+		return err
 	}
 
+	search.Suggestions.Invalidate()
+
 	// Refresh with translations
 	return config.DB.Preload("Translations").First(c, c.ID).Error
 }
+
+// checkParent verifies that parentID exists and, for an existing category id,
+// is not the category itself or one of its descendants.
+func checkParent(tx *gorm.DB, id uint, parentID *uint) error {
+	if parentID == nil {
+		return nil
+	}
+	var count int64
+	if err := tx.Model(&models.Category{}).Where("id = ?", *parentID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrParentNotFound
+	}
+	if id == 0 {
+		return nil
+	}
+
+	var below []uint
+	if err := tx.Raw(descendantsSQL, []uint{id}).Scan(&below).Error; err != nil {
+		return err
+	}
+	for _, d := range below {
+		if d == *parentID {
+			return ErrCategoryCycle
+		}
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+package repository
+
+import (
+	"bogbon-api/config"
+	"bogbon-api/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrCrewNotFound       = errors.New("crew not found")
+	ErrNoServiceItems     = errors.New("order has no matching service items")
+	ErrAssignmentWindow   = errors.New("assignment needs a time window: pass start and end times or book slots on the items")
+	ErrAssignmentNotFound = errors.New("assignment not found")
+	ErrWorkerNotFound     = errors.New("worker not found")
+	ErrCrewInactive       = errors.New("crew is not active")
+	ErrOrderClosed        = errors.New("order is cancelled, refunded or completed")
+)
+
+// AssignmentConflictError is returned when a crew already has overlapping jobs.
+type AssignmentConflictError struct {
+	Conflicts []models.CrewAssignment
+}
+
+func (e *AssignmentConflictError) Error() string {
+	return fmt.Sprintf("crew already has %d overlapping job(s)", len(e.Conflicts))
+}
+
+// GetAllCrews returns every crew with its workers.
+func GetAllCrews() ([]models.Crew, error) {
+	var crews []models.Crew
+	err := config.DB.Preload("Workers").Order("id").Find(&crews).Error
+	return crews, err
+}
+
+// CreateCrew stores a new crew.
+func CreateCrew(crew *models.Crew) error {
+	return config.DB.Create(crew).Error
+}
+
+// UpdateCrew saves the given columns of a crew, e.g. its name and active flag.
+func UpdateCrew(crew *models.Crew, columns []string) error {
+	res := config.DB.Model(&models.Crew{}).Where("id = ?", crew.ID).
+		Select(columns).
+		Updates(crew)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrCrewNotFound
+	}
+	return config.DB.Preload("Workers").First(crew, crew.ID).Error
+}
+
+// DeleteCrew removes a crew with its assignments and detaches its workers.
+// The orders it was assigned to can then be dispatched to another crew.
+func DeleteCrew(id uint) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("crew_id = ?", id).Delete(&models.CrewAssignment{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Worker{}).Where("crew_id = ?", id).
+			Update("crew_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Crew{}, id).Error
+	})
+}
+
+// CreateWorker stores a new worker. A crew it joins must exist.
+func CreateWorker(worker *models.Worker) error {
+	if err := checkCrew(worker.CrewID); err != nil {
+		return err
+	}
+	return config.DB.Create(worker).Error
+}
+
+// UpdateWorker saves a worker's details and crew membership.
+func UpdateWorker(worker *models.Worker) error {
+	if err := checkCrew(worker.CrewID); err != nil {
+		return err
+	}
+	res := config.DB.Model(&models.Worker{}).Where("id = ?", worker.ID).
+		Select("crew_id", "name", "phone", "active").
+		Updates(worker)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrWorkerNotFound
+	}
+	return config.DB.First(worker, worker.ID).Error
+}
+
+// checkCrew makes sure the crew a worker is put in exists; nil means none.
+func checkCrew(crewID *uint) error {
+	if crewID == nil {
+		return nil
+	}
+	var count int64
+	if err := config.DB.Model(&models.Crew{}).Where("id = ?", *crewID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrCrewNotFound
+	}
+	return nil
+}
+
+// DeleteWorker removes a worker by ID.
+func DeleteWorker(id uint) error {
+	return config.DB.Delete(&models.Worker{}, id).Error
+}
+
+// AssignOrderToCrew dispatches an order's service items to a crew. When start
+// and end are nil, each item's booked slot is used as its window. Items that
+// were already assigned are moved. Overlapping jobs of the crew from other
+// orders are rejected with an AssignmentConflictError. Inactive crews and
+// orders that are cancelled, refunded or completed cannot be assigned.
+func AssignOrderToCrew(orderID, crewID uint, itemIDs []uint, start, end *time.Time, note string) ([]models.CrewAssignment, error) {
+	var assignments []models.CrewAssignment
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// Lock the crew so concurrent assignments are checked one at a time
+		var crew models.Crew
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&crew, crewID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrCrewNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if !crew.Active {
+			return ErrCrewInactive
+		}
+
+		// Lock the order too, so it is not cancelled while being assigned
+		var order models.Order
+		err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, orderID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrOrderNotFound
+		}
+		if err != nil {
+			return err
+		}
+		switch order.Status {
+		case models.OrderCancelled, models.OrderRefunded, models.OrderCompleted:
+			return ErrOrderClosed
+		}
+
+		q := tx.Where("order_id = ? AND product_type = ?", orderID, models.ProductTypeService)
+		if len(itemIDs) > 0 {
+			q = q.Where("id IN ?", itemIDs)
+		}
+		var items []models.OrderItem
+		if err := q.Order("id").Find(&items).Error; err != nil {
+			return err
+		}
+		if len(items) == 0 || (len(itemIDs) > 0 && len(items) != len(itemIDs)) {
+			return ErrNoServiceItems
+		}
+
+		for _, item := range items {
+			a := models.CrewAssignment{
+				CrewID:      crewID,
+				OrderID:     orderID,
+				OrderItemID: item.ID,
+				Note:        note,
+			}
+			switch {
+			case start != nil && end != nil:
+				a.StartsAt, a.EndsAt = *start, *end
+			case item.SlotStart != nil && item.SlotEnd != nil:
+				a.StartsAt, a.EndsAt = *item.SlotStart, *item.SlotEnd
+			default:
+				return ErrAssignmentWindow
+			}
+			assignments = append(assignments, a)
+		}
+
+		// Detect overlaps with the crew's jobs from other orders
+		var conflicts []models.CrewAssignment
+		for _, a := range assignments {
+			var found []models.CrewAssignment
+			if err := tx.Where("crew_id = ? AND order_id <> ? AND starts_at < ? AND ends_at > ?",
+				crewID, orderID, a.EndsAt, a.StartsAt).Find(&found).Error; err != nil {
+				return err
+			}
+			conflicts = append(conflicts, found...)
+		}
+		if len(conflicts) > 0 {
+			return &AssignmentConflictError{Conflicts: conflicts}
+		}
+
+		ids := make([]uint, len(items))
+		for i, item := range items {
+			ids[i] = item.ID
+		}
+		if err := tx.Where("order_item_id IN ?", ids).Delete(&models.CrewAssignment{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&assignments).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return assignments, nil
+}
+
+// DeleteAssignment removes a crew assignment by ID.
+func DeleteAssignment(id uint) error {
+	res := config.DB.Delete(&models.CrewAssignment{}, id)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrAssignmentNotFound
+	}
+	return nil
+}
+
+// GetCrewSchedule returns a crew's jobs on the given day, in time order.
+func GetCrewSchedule(crewID uint, day time.Time) ([]models.CrewAssignment, error) {
+	day = day.In(config.Location)
+	dayStart := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, config.Location)
+
+	var assignments []models.CrewAssignment
+	err := config.DB.Preload("Order").Preload("OrderItem.Translations").
+		Where("crew_id = ? AND starts_at < ? AND ends_at > ?", crewID, dayStart.AddDate(0, 0, 1), dayStart).
+		Order("starts_at").
+		Find(&assignments).Error
+	return assignments, err
+}
@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"bogbon-api/config"
+	"bogbon-api/models"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var ErrCustomerExists = errors.New("an account with this phone or email already exists")
+
+// RegisterCustomer creates a customer account with a hashed password.
+func RegisterCustomer(name string, phone, email *string, password string) (*models.Customer, error) {
+	var count int64
+	q := config.DB.Model(&models.Customer{})
+	switch {
+	case phone != nil && email != nil:
+		q = q.Where("phone = ? OR email = ?", *phone, *email)
+	case phone != nil:
+		q = q.Where("phone = ?", *phone)
+	default:
+		q = q.Where("email = ?", *email)
+	}
+	if err := q.Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrCustomerExists
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	customer := models.Customer{
+		Name:         name,
+		Phone:        phone,
+		Email:        email,
+		PasswordHash: string(hash),
+		SessionID:    uuid.NewString(),
+	}
+	if err := config.DB.Create(&customer).Error; err != nil {
+		return nil, err
+	}
+	return &customer, nil
+}
+
+// AuthenticateCustomer checks a phone-or-email login and password.
+func AuthenticateCustomer(login, password string) (*models.Customer, error) {
+	var customer models.Customer
+	err := config.DB.Where("phone = ? OR email = ?", login, strings.ToLower(login)).First(&customer).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	if customer.PasswordHash == "" ||
+		bcrypt.CompareHashAndPassword([]byte(customer.PasswordHash), []byte(password)) != nil {
+		return nil, ErrInvalidCredentials
+	}
+	return &customer, nil
+}
+
+// GetCustomerByID fetches a customer by ID.
+func GetCustomerByID(id uint) (*models.Customer, error) {
+	var customer models.Customer
+	if err := config.DB.First(&customer, id).Error; err != nil {
+		return nil, err
+	}
+	return &customer, nil
+}
+
+// GetOrdersByCustomer returns the customer's orders, newest first.
+func GetOrdersByCustomer(customerID uint) ([]models.Order, error) {
+	var orders []models.Order
+	err := config.DB.Scopes(withOrderDetails).
+		Where("customer_id = ?", customerID).
+		Order("created_at DESC").
+		Find(&orders).Error
+	return orders, err
+}
+
+// MergeSessionIntoCustomer moves an anonymous session's cart items into the
+// customer's cart and re-links that session's orders to the customer.
+func MergeSessionIntoCustomer(anonSessionID string, customer *models.Customer) error {
+	if anonSessionID == "" || anonSessionID == customer.SessionID {
+		return nil
+	}
+
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		// Re-link order history
+		if err := tx.Model(&models.Order{}).
+			Where("session_id = ?", anonSessionID).
+			Updates(map[string]interface{}{
+				"session_id":  customer.SessionID,
+				"customer_id": customer.ID,
+			}).Error; err != nil {
+			return err
+		}
+
+		var anonCart models.Cart
+		err := tx.Preload("Items").Where("session_id = ?", anonSessionID).First(&anonCart).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var accountCart models.Cart
+		err = tx.Preload("Items").Where("session_id = ?", customer.SessionID).First(&accountCart).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// No account cart yet: adopt the anonymous one as-is
+			return tx.Model(&anonCart).Update("session_id", customer.SessionID).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		// Lines match on product, variant and, for services, on the booked slot
+		lineKey := func(item models.CartItem) string {
+			key := fmt.Sprint(item.ProductID)
+			if item.VariantID != nil {
+				key += fmt.Sprintf("/%d", *item.VariantID)
+			}
+			if item.SlotStart != nil {
+				key += fmt.Sprintf("@%d", item.SlotStart.Unix())
+			}
+			return key
+		}
+		existing := make(map[string]models.CartItem, len(accountCart.Items))
+		for _, item := range accountCart.Items {
+			existing[lineKey(item)] = item
+		}
+
+		for _, item := range anonCart.Items {
+			if match, ok := existing[lineKey(item)]; ok {
+				if err := tx.Model(&models.CartItem{}).Where("id = ?", match.ID).
+					Update("quantity", match.Quantity+item.Quantity).Error; err != nil {
+					return err
+				}
+				if err := tx.Delete(&models.CartItem{}, item.ID).Error; err != nil {
+					return err
+				}
+				continue
+			}
+			if err := tx.Model(&models.CartItem{}).Where("id = ?", item.ID).
+				Update("cart_id", accountCart.ID).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Delete(&models.Cart{}, anonCart.ID).Error
+	})
+}
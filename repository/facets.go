@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"bogbon-api/config"
+	"bogbon-api/models"
+)
+
+// PriceBucketEdges splits prices into facet buckets: below the first edge,
+// from one edge up to the next, and from the last edge up.
+var PriceBucketEdges = []int{50000, 100000, 250000, 500000}
+
+// CategoryFacet is the number of matching products in one category.
+type CategoryFacet struct {
+	ID    uint   `json:"id"`
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
+// TypeFacet is the number of matching products of one type.
+type TypeFacet struct {
+	Type  string `json:"type"`
+	Count int64  `json:"count"`
+}
+
+// PriceFacet is the number of matching products with a price in [Min, Max],
+// counted like the min_price/max_price filter: products with variants count
+// once in every bucket one of their variants is in. Max is one below the next
+// bucket's Min, so the bounds can be passed to the filter as they are. A nil
+// bound is open.
+type PriceFacet struct {
+	Min   *int  `json:"min"`
+	Max   *int  `json:"max"`
+	Count int64 `json:"count"`
+}
+
+// StockFacet splits matching products by availability.
+type StockFacet struct {
+	InStock    int64 `json:"in_stock"`
+	OutOfStock int64 `json:"out_of_stock"`
+}
+
+// Facets are the sidebar counts for a product search.
+type Facets struct {
+	Categories []CategoryFacet `json:"categories"`
+	Types      []TypeFacet     `json:"types"`
+	Prices     []PriceFacet    `json:"prices"`
+	Stock      StockFacet      `json:"stock"`
+}
+
+// ProductFacets counts the products matching f per category, type, price
+// bucket and availability. Each facet applies every filter except its own, so
+// picking one category still shows how many products the other categories
+// would give. With f.SubCategories a category counts the products of its
+// subcategories too, as filtering by it would. Category names are in f.Lang.
+func ProductFacets(f ProductFilter) (*Facets, error) {
+	facets := &Facets{
+		Categories: []CategoryFacet{},
+		Types:      []TypeFacet{},
+		Prices:     []PriceFacet{},
+	}
+
+	without := f
+	without.CategoryIDs = nil
+	scope, _, err := productFilterScope(without)
+	if err != nil {
+		return nil, err
+	}
+	membership := "SELECT category_id AS root, product_id FROM category_products"
+	if f.SubCategories {
+		membership = "SELECT t.root, cp.product_id FROM category_products cp JOIN (" + subtreeSQL + ") t ON t.id = cp.category_id"
+	}
+	err = config.DB.Model(&models.Product{}).Scopes(scope).
+		Joins("JOIN ("+membership+") m ON m.product_id = products.id").
+		Joins("LEFT JOIN category_translations ct ON ct.category_id = m.root AND ct.language_code = ?", f.Lang).
+		Select("m.root AS id, COALESCE(MAX(ct.name), '') AS name, COUNT(DISTINCT products.id) AS count").
+		Group("m.root").
+		Order("count DESC, m.root").
+		Scan(&facets.Categories).Error
+	if err != nil {
+		return nil, err
+	}
+
+	without = f
+	without.Type = ""
+	if scope, _, err = productFilterScope(without); err != nil {
+		return nil, err
+	}
+	err = config.DB.Model(&models.Product{}).Scopes(scope).
+		Select("products.type AS type, COUNT(DISTINCT products.id) AS count").
+		Group("products.type").
+		Order("products.type").
+		Scan(&facets.Types).Error
+	if err != nil {
+		return nil, err
+	}
+
+	without = f
+	without.MinPrice, without.MaxPrice = nil, nil
+	if scope, _, err = productFilterScope(without); err != nil {
+		return nil, err
+	}
+	var buckets []struct {
+		Bucket int
+		Count  int64
+	}
+	err = config.DB.Model(&models.Product{}).Scopes(scope).
+		Joins("JOIN LATERAL (" + sellingPricesSQL + ") sp ON TRUE").
+		Select(priceBucketSQL("sp.price") + " AS bucket, COUNT(DISTINCT products.id) AS count").
+		Group("bucket").
+		Scan(&buckets).Error
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[int]int64, len(buckets))
+	for _, b := range buckets {
+		counts[b.Bucket] = b.Count
+	}
+	for i := 0; i <= len(PriceBucketEdges); i++ {
+		bucket := PriceFacet{Count: counts[i]}
+		if i > 0 {
+			bucket.Min = &PriceBucketEdges[i-1]
+		}
+		if i < len(PriceBucketEdges) {
+			last := PriceBucketEdges[i] - 1
+			bucket.Max = &last
+		}
+		facets.Prices = append(facets.Prices, bucket)
+	}
+
+	without = f
+	without.InStock = nil
+	if scope, _, err = productFilterScope(without); err != nil {
+		return nil, err
+	}
+	err = config.DB.Model(&models.Product{}).Scopes(scope).
+		Select("COUNT(DISTINCT products.id) FILTER (WHERE " + stockSQL + " > 0) AS in_stock, " +
+			"COUNT(DISTINCT products.id) FILTER (WHERE " + stockSQL + " <= 0) AS out_of_stock").
+		Scan(&facets.Stock).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return facets, nil
+}
+
+// sellingPricesSQL lists the prices a product sells at, one row each: those
+// of its variants, or its own price when it has no variants.
+const sellingPricesSQL = `SELECT v.price FROM product_variants v WHERE v.product_id = products.id
+	UNION ALL SELECT products.price WHERE NOT EXISTS (SELECT 1 FROM product_variants v WHERE v.product_id = products.id)`
+
+// priceBucketSQL maps the price in column to its index in PriceBucketEdges:
+// the number of edges at or below it.
+func priceBucketSQL(column string) string {
+	if len(PriceBucketEdges) == 0 {
+		return "0"
+	}
+	edges := make([]string, len(PriceBucketEdges))
+	for i, edge := range PriceBucketEdges {
+		edges[i] = strconv.Itoa(edge)
+	}
+	return fmt.Sprintf("(SELECT COUNT(*) FROM unnest(ARRAY[%s]) AS edge WHERE edge <= %s)",
+		strings.Join(edges, ", "), column)
+}
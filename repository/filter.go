@@ -1,107 +1,223 @@
 package repository
 
 import (
-	"fmt"
-	"sort"
 	"strings"
 
 	"bogbon-api/config"
 	"bogbon-api/models"
+
+	"gorm.io/gorm"
 )
 
 type ProductFilter struct {
-	MinPrice    *int
-	MaxPrice    *int
-	Type        string
-	InStock     *bool
-	CategoryIDs []uint
-	Q           string
-	IsOriginal  bool
+	MinPrice      *int
+	MaxPrice      *int
+	Type          string
+	InStock       *bool
+	CategoryIDs   []uint
+	SubCategories bool // match products in descendants of CategoryIDs too
+	Q             string
+	Lang          string            // language searched by Q
+	Attributes    map[string]string // ?attr[code]=value, see attributeFilters
 }
 
 type CategoryFilter struct {
-	Q string
+	Q    string
+	Lang string // language names are sorted in
 }
 
-func FilterProducts(f ProductFilter, includeImages bool) ([]models.Product, error) {
-	var products []models.Product
-
-	query := config.DB.Preload("Categories").Preload("Translations").Preload("Images", "is_original = ?", f.IsOriginal)
-
-	// Apply filters to the query
-	if f.MinPrice != nil {
-		query = query.Where("price >= ?", f.MinPrice)
+// FilterProducts returns one page of the products matching f.
+func FilterProducts(f ProductFilter, p PageParams) ([]models.Product, PageMeta, error) {
+	scope, rank, err := productFilterScope(f)
+	if err != nil {
+		return nil, PageMeta{}, err
 	}
-	if f.MaxPrice != nil {
-		query = query.Where("price <= ?", f.MaxPrice)
+
+	sorts := productSorts(f.Lang)
+	def := "id"
+	if rank != nil {
+		sorts["relevance"] = *rank
+		def = "-relevance"
 	}
-	if f.Type != "" {
-		query = query.Where("type = ?", f.Type)
+	sortName, opt, desc, err := resolveSort(p, def, sorts)
+	if err != nil {
+		return nil, PageMeta{}, err
 	}
-	if f.InStock != nil {
-		query = query.Where("stock > ?", 0)
+
+	ids, meta, err := paginate(config.DB.Model(&models.Product{}).Scopes(scope), "products.id", p, sortName, opt, desc)
+	if err != nil {
+		return nil, meta, err
 	}
-	if len(f.CategoryIDs) > 0 {
-		query = query.Joins("JOIN category_products ON category_products.product_id = products.id").
-			Where("category_products.category_id IN ?", f.CategoryIDs)
+
+	var products []models.Product
+	if len(ids) > 0 {
+		err := config.DB.Preload("Categories").Preload("Translations").Preload("Variants.Options").
+			Preload("Attributes.Attribute.Translations").Preload("Attributes.Value.Translations").
+			Scopes(preloadImages).
+			Where("id IN ?", ids).
+			Find(&products).Error
+		if err != nil {
+			return nil, meta, err
+		}
 	}
+	products = inIDOrder(products, ids, func(p models.Product) uint { return p.ID })
+
+	return products, meta, nil
+}
+
+// productFilterScope turns f into query conditions on products. When f.Q is
+// a search, it also returns the relevance sort for the matches.
+func productFilterScope(f ProductFilter) (func(*gorm.DB) *gorm.DB, *sortOption, error) {
+	var textSQL string
+	var textVars []interface{}
 	if f.Q != "" {
-		query = query.Where("name LIKE ?", "%"+f.Q+"%")
+		var err error
+		textSQL, textVars, err = textMatch("pt.search_text", f.Q)
+		if err != nil {
+			return nil, nil, err
+		}
 	}
 
-	// Execute the query
-	if err := query.Find(&products).Error; err != nil {
-		return nil, err
+	attrConds, attrVars, err := attributeFilters(f.Attributes)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	// If images should be included, sort images after querying
-	for i := range products {
-		// Sort images array: 'default' images first
-		images := products[i].Images
-		products[i].Images = sortImagesByDefault(images)
+	scope := func(query *gorm.DB) *gorm.DB {
+		for i, cond := range attrConds {
+			query = query.Where(cond, attrVars[i]...)
+		}
+		if f.MinPrice != nil || f.MaxPrice != nil {
+			// Products with variants match when any variant is in range
+			cond, vars := priceRange("products.price", f)
+			variantCond, variantVars := priceRange("v.price", f)
+			query = query.Where("((NOT EXISTS (SELECT 1 FROM product_variants v WHERE v.product_id = products.id) AND "+cond+")"+
+				" OR EXISTS (SELECT 1 FROM product_variants v WHERE v.product_id = products.id AND "+variantCond+"))",
+				append(vars, variantVars...)...)
+		}
+		if f.Type != "" {
+			query = query.Where("type = ?", f.Type)
+		}
+		if f.InStock != nil {
+			if *f.InStock {
+				query = query.Where(stockSQL+" > ?", 0)
+			} else {
+				query = query.Where(stockSQL+" <= ?", 0)
+			}
+		}
+		if len(f.CategoryIDs) > 0 {
+			if f.SubCategories {
+				query = query.Where("products.id IN (SELECT product_id FROM category_products WHERE category_id IN ("+descendantsSQL+"))", f.CategoryIDs)
+			} else {
+				query = query.Where("products.id IN (SELECT product_id FROM category_products WHERE category_id IN ?)", f.CategoryIDs)
+			}
+		}
+		if textSQL != "" {
+			// Full-text search over the requested language's translation, plus
+			// transliteration- and synonym-aware matching on the normalized text.
+			query = query.
+				Joins("JOIN product_translations pt ON pt.product_id = products.id AND pt.language_code = ?", f.Lang).
+				Where("(pt.search_vector @@ to_tsquery(?::regconfig, ?) OR "+textSQL+")",
+					append([]interface{}{searchConfig(f.Lang), prefixTSQuery(f.Q)}, textVars...)...)
+		}
+		return query
 	}
 
-	fmt.Println("THE QUERY: ", query)
+	if textSQL == "" {
+		return scope, nil, nil
+	}
+	rank := sortOption{
+		expr:    "CAST(ts_rank(pt.search_vector, to_tsquery(?::regconfig, ?)) AS double precision) + CASE WHEN " + textSQL + " THEN 0.1 ELSE 0 END",
+		vars:    append([]interface{}{searchConfig(f.Lang), prefixTSQuery(f.Q)}, textVars...),
+		sqlType: "double precision",
+	}
+	return scope, &rank, nil
+}
+
+// fromPriceSQL is the lowest price a product sells at: its cheapest variant,
+// or its own price when it has no variants.
+const fromPriceSQL = "COALESCE((SELECT MIN(v.price) FROM product_variants v WHERE v.product_id = products.id), products.price)"
+
+// stockSQL is a product's stock summed over its variants, or its own stock
+// when it has no variants.
+const stockSQL = "COALESCE((SELECT SUM(v.stock) FROM product_variants v WHERE v.product_id = products.id), products.stock)"
 
-	return products, nil
+// priceRange builds the f.MinPrice/f.MaxPrice condition on column.
+func priceRange(column string, f ProductFilter) (string, []interface{}) {
+	var conds []string
+	var vars []interface{}
+	if f.MinPrice != nil {
+		conds = append(conds, column+" >= ?")
+		vars = append(vars, *f.MinPrice)
+	}
+	if f.MaxPrice != nil {
+		conds = append(conds, column+" <= ?")
+		vars = append(vars, *f.MaxPrice)
+	}
+	return strings.Join(conds, " AND "), vars
 }
 
-// sortImagesByDefault sorts images such that images with 'default' in the URL appear first.
-func sortImagesByDefault(images []models.ProductImage) []models.ProductImage {
-	sortedImages := make([]models.ProductImage, len(images))
-	copy(sortedImages, images)
+// productSorts lists the ?sort= values of the product list. Names are
+// compared in the requested language.
+func productSorts(lang string) map[string]sortOption {
+	return map[string]sortOption{
+		"id":         {expr: "products.id", sqlType: "bigint"},
+		"price":      {expr: fromPriceSQL, sqlType: "bigint"},
+		"created_at": {expr: "products.created_at", sqlType: "timestamptz"},
+		"name": {
+			expr:    "COALESCE((SELECT name FROM product_translations WHERE product_id = products.id AND language_code = ? LIMIT 1), '')",
+			vars:    []interface{}{lang},
+			sqlType: "text",
+		},
+		"popularity": {
+			// Units sold, not counting cancelled or refunded orders
+			expr: "COALESCE((SELECT SUM(oi.quantity) FROM order_items oi JOIN orders o ON o.id = oi.order_id" +
+				" WHERE oi.product_id = products.id AND o.status NOT IN ('" + models.OrderCancelled + "', '" + models.OrderRefunded + "')), 0)",
+			sqlType: "bigint",
+		},
+	}
+}
 
-	sort.SliceStable(sortedImages, func(i, j int) bool {
-		isDefaultI := strings.Contains(sortedImages[i].URL, "default")
-		isDefaultJ := strings.Contains(sortedImages[j].URL, "default")
+// FilterCategories returns one page of the categories whose name matches
+// f.Q in any language. Names are sorted in f.Lang.
+func FilterCategories(f CategoryFilter, p PageParams) ([]models.Category, PageMeta, error) {
+	db := config.DB.Model(&models.Category{})
 
-		if isDefaultI && !isDefaultJ {
-			return true
+	if f.Q != "" {
+		// Match any spelling (Latin/Cyrillic, apostrophe variants, synonyms) of the name
+		textSQL, textVars, err := textMatch("ct.search_text", f.Q)
+		if err != nil {
+			return nil, PageMeta{}, err
 		}
-		if !isDefaultI && isDefaultJ {
-			return false
+		if textSQL != "" {
+			db = db.Where("categories.id IN (SELECT ct.category_id FROM category_translations ct WHERE "+textSQL+")", textVars...)
 		}
+	}
 
-		return sortedImages[i].ID < sortedImages[j].ID
+	sortName, opt, desc, err := resolveSort(p, "name", map[string]sortOption{
+		"id":         {expr: "categories.id", sqlType: "bigint"},
+		"position":   {expr: "categories.position", sqlType: "bigint"},
+		"created_at": {expr: "categories.created_at", sqlType: "timestamptz"},
+		"name": {
+			expr:    "COALESCE((SELECT name FROM category_translations WHERE category_id = categories.id AND language_code = ? LIMIT 1), '')",
+			vars:    []interface{}{f.Lang},
+			sqlType: "text",
+		},
 	})
+	if err != nil {
+		return nil, PageMeta{}, err
+	}
 
-	return sortedImages
-}
-
-// FilterCategories by translation name
-func FilterCategories(f CategoryFilter) ([]models.Category, error) {
-	db := config.DB.
-		Model(&models.Category{}).
-		Preload("Translations")
-
-	if f.Q != "" {
-		like := "%" + strings.ToLower(f.Q) + "%"
-		db = db.Joins("JOIN category_translations ct ON ct.category_id = categories.id").
-			Where("LOWER(ct.name) LIKE ?", like).
-			Group("categories.id")
+	ids, meta, err := paginate(db, "categories.id", p, sortName, opt, desc)
+	if err != nil {
+		return nil, meta, err
 	}
 
 	var cats []models.Category
-	err := db.Find(&cats).Error
-	return cats, err
+	if len(ids) > 0 {
+		if err := config.DB.Preload("Translations").Where("id IN ?", ids).Find(&cats).Error; err != nil {
+			return nil, meta, err
+		}
+	}
+	return inIDOrder(cats, ids, func(c models.Category) uint { return c.ID }), meta, nil
 }
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"bogbon-api/config"
+	"bogbon-api/models"
+	"bogbon-api/storage"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// imageKeys returns the storage keys of the originals and renditions of the
+// images with ids.
+func imageKeys(tx *gorm.DB, ids []uint) ([]string, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	var keys []string
+	err := tx.Raw(`SELECT storage_key FROM product_images WHERE id IN ? AND COALESCE(storage_key, '') <> ''
+		UNION SELECT storage_key FROM image_renditions WHERE image_id IN ? AND COALESCE(storage_key, '') <> ''`,
+		ids, ids).Scan(&keys).Error
+	return keys, err
+}
+
+// deleteStoredFiles removes files whose rows are gone. Failures are only
+// logged: the image garbage collector removes whatever is left behind.
+func deleteStoredFiles(keys []string) {
+	for _, key := range keys {
+		if err := storage.Files.Delete(key); err != nil {
+			log.Printf("delete %s: %v", key, err)
+		}
+	}
+}
+
+// imagePrefixes are the key prefixes image uploads are stored under, now and
+// before renditions existed. The collector never touches keys outside them,
+// as the bucket may be shared with other files.
+var imagePrefixes = []string{"originals/", "renditions/", "min_uploads/", "max_uploads/"}
+
+// ImageGCReport is what a garbage collection found, or removed.
+type ImageGCReport struct {
+	Scanned        int              // stored files looked at
+	Orphans        []storage.Object // files no image, rendition or queued job refers to
+	OrphanBytes    int64
+	DeletedImages  int64 // images of deleted products
+	SkippedRecents int   // unreferenced files younger than minAge, possibly mid-upload
+}
+
+// CollectImageGarbage reconciles storage.Files with the database. Images of
+// deleted products are removed, then every stored file under imagePrefixes
+// that no image, rendition or unfinished upload job refers to is deleted.
+// Files younger than minAge are spared, as an upload may not have saved its
+// row yet. With dryRun nothing is changed and the report lists what would go.
+func CollectImageGarbage(minAge time.Duration, dryRun bool) (*ImageGCReport, error) {
+	report := &ImageGCReport{}
+
+	// Images of products deleted before their images were cleaned up with them
+	deleted := config.DB.Model(&models.ProductImage{}).
+		Where("product_id NOT IN (SELECT id FROM products WHERE deleted_at IS NULL)")
+	if dryRun {
+		if err := deleted.Count(&report.DeletedImages).Error; err != nil {
+			return nil, err
+		}
+	} else {
+		res := deleted.Delete(&models.ProductImage{})
+		if res.Error != nil {
+			return nil, res.Error
+		}
+		report.DeletedImages = res.RowsAffected
+	}
+
+	// In a dry run those images still exist, so leave their files out too
+	var keys []string
+	err := config.DB.Raw(`SELECT pi.storage_key FROM product_images pi
+			JOIN products p ON p.id = pi.product_id AND p.deleted_at IS NULL
+		UNION SELECT ir.storage_key FROM image_renditions ir
+			JOIN product_images pi ON pi.id = ir.image_id
+			JOIN products p ON p.id = pi.product_id AND p.deleted_at IS NULL
+		UNION SELECT source_key FROM image_jobs WHERE status IN ?`,
+		[]string{models.ImageJobPending, models.ImageJobProcessing}).Scan(&keys).Error
+	if err != nil {
+		return nil, err
+	}
+	referenced := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		referenced[key] = true
+	}
+
+	var objects []storage.Object
+	for _, prefix := range imagePrefixes {
+		listed, err := storage.Files.List(prefix)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, listed...)
+	}
+	report.Scanned = len(objects)
+	cutoff := time.Now().Add(-minAge)
+	for _, obj := range objects {
+		if referenced[obj.Key] {
+			continue
+		}
+		if obj.ModTime.After(cutoff) {
+			report.SkippedRecents++
+			continue
+		}
+		if !dryRun {
+			if err := storage.Files.Delete(obj.Key); err != nil {
+				return report, err
+			}
+		}
+		report.Orphans = append(report.Orphans, obj)
+		report.OrphanBytes += obj.Size
+	}
+	return report, nil
+}
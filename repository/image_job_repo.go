@@ -0,0 +1,228 @@
+package repository
+
+import (
+	"bogbon-api/config"
+	"bogbon-api/images"
+	"bogbon-api/models"
+	"bogbon-api/storage"
+	"errors"
+	"path"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrImageJobNotFound = errors.New("image job not found")
+	// ErrImageJobLost means the job was requeued, and possibly claimed again,
+	// while this worker still held it; the result is dropped.
+	ErrImageJobLost = errors.New("image job no longer held by this worker")
+)
+
+// MaxImageJobAttempts is how often a job runs before it is marked failed.
+const MaxImageJobAttempts = 5
+
+// imageJobBackoff is the wait before the retry that follows attempt n.
+func imageJobBackoff(n int) time.Duration {
+	return time.Duration(1<<(n-1)) * 30 * time.Second
+}
+
+// CreateImageJobs queues rendition jobs for uploaded originals and wakes the
+// image workers.
+func CreateImageJobs(jobs []models.ImageJob) error {
+	now := time.Now()
+	for i := range jobs {
+		jobs[i].Status = models.ImageJobPending
+		jobs[i].RunAt = now
+	}
+	if err := config.DB.Create(&jobs).Error; err != nil {
+		return err
+	}
+	images.Notify()
+	return nil
+}
+
+// GetImageJob returns a job with its image and renditions once done.
+func GetImageJob(id uint) (*models.ImageJob, error) {
+	var job models.ImageJob
+	err := config.DB.Preload("Image.Renditions").First(&job, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrImageJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ClaimImageJob marks the oldest due pending job as processing and returns
+// it, or nil when there is none. Jobs claimed by other workers, in this or
+// another instance, are skipped.
+func ClaimImageJob() (*models.ImageJob, error) {
+	var job models.ImageJob
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND run_at <= ?", models.ImageJobPending, time.Now()).
+			Order("run_at, id").
+			First(&job).Error
+		if err != nil {
+			return err
+		}
+		// Postgres keeps microseconds; locked_at identifies the claim later
+		now := time.Now().Truncate(time.Microsecond)
+		job.Status = models.ImageJobProcessing
+		job.Attempts++
+		job.LockedAt = &now
+		return tx.Model(&job).Select("status", "attempts", "locked_at").Updates(&job).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// CompleteImageJob saves the image of a processed job with its renditions,
+// or for backfills the renditions of the existing image.
+// Only the claim that is still current may complete the job, so a worker
+// whose job was requeued as stale does not add the image a second time.
+func CompleteImageJob(job *models.ImageJob, renditions []models.ImageRendition) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		res := heldImageJob(tx, job).
+			Updates(map[string]interface{}{"status": models.ImageJobDone, "error": ""})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrImageJobLost
+		}
+
+		if job.Backfill {
+			return saveRenditions(tx, job.ImageID, renditions)
+		}
+
+		img, err := addProductImage(tx, job.ProductID, job.SourceKey, renditions)
+		if err != nil {
+			return err
+		}
+		job.Status = models.ImageJobDone
+		job.Error = ""
+		job.ImageID = &img.ID
+		return tx.Model(job).Update("image_id", img.ID).Error
+	})
+}
+
+// saveRenditions replaces the renditions of image imageID by name. The image
+// may have been deleted since the backfill was queued.
+func saveRenditions(tx *gorm.DB, imageID *uint, renditions []models.ImageRendition) error {
+	if imageID == nil {
+		return ErrImageNotFound
+	}
+	var img models.ProductImage
+	err := tx.First(&img, *imageID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrImageNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	for i := range renditions {
+		renditions[i].ImageID = img.ID
+		renditions[i].URL = storage.Files.URL(renditions[i].StorageKey)
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "image_id"}, {Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"width", "height", "url", "storage_key", "spec"}),
+	}).Create(&renditions).Error
+}
+
+// QueueMissingRenditions queues backfill jobs for images lacking one of the
+// configured renditions, or holding one rendered with other settings, e.g.
+// images from before renditions existed or after IMAGE_RENDITIONS changed.
+// Images with a backfill already queued are left alone. It returns how many
+// jobs were queued.
+func QueueMissingRenditions() (int, error) {
+	want := make([][]interface{}, len(images.Renditions))
+	for i, r := range images.Renditions {
+		want[i] = []interface{}{r.Name, r.Spec()}
+	}
+
+	var imgs []models.ProductImage
+	err := config.DB.
+		Where("is_original = ? AND COALESCE(storage_key, '') <> ''", true).
+		Where("product_id IN (SELECT id FROM products WHERE deleted_at IS NULL)").
+		Where("(SELECT COUNT(*) FROM image_renditions ir WHERE ir.image_id = product_images.id AND (ir.name, ir.spec) IN ?) < ?",
+			want, len(want)).
+		Where("NOT EXISTS (SELECT 1 FROM image_jobs j WHERE j.image_id = product_images.id AND j.backfill AND j.status IN ?)",
+			[]string{models.ImageJobPending, models.ImageJobProcessing}).
+		Find(&imgs).Error
+	if err != nil || len(imgs) == 0 {
+		return 0, err
+	}
+
+	jobs := make([]models.ImageJob, len(imgs))
+	for i, img := range imgs {
+		id := img.ID
+		jobs[i] = models.ImageJob{
+			ProductID: img.ProductID,
+			Filename:  path.Base(img.StorageKey),
+			SourceKey: img.StorageKey,
+			ImageID:   &id,
+			Backfill:  true,
+		}
+	}
+	if err := CreateImageJobs(jobs); err != nil {
+		return 0, err
+	}
+	return len(jobs), nil
+}
+
+// FailImageJob records a failed attempt. Jobs are retried with growing
+// delays until MaxImageJobAttempts, unless retry is false.
+func FailImageJob(job *models.ImageJob, cause error, retry bool) error {
+	job.Error = cause.Error()
+	if retry && job.Attempts < MaxImageJobAttempts {
+		job.Status = models.ImageJobPending
+		job.RunAt = time.Now().Add(imageJobBackoff(job.Attempts))
+	} else {
+		job.Status = models.ImageJobFailed
+	}
+	res := heldImageJob(config.DB, job).Select("status", "error", "run_at").Updates(job)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrImageJobLost
+	}
+	return nil
+}
+
+// heldImageJob scopes tx to job as long as the claim that loaded it is
+// still current.
+func heldImageJob(tx *gorm.DB, job *models.ImageJob) *gorm.DB {
+	return tx.Model(&models.ImageJob{}).
+		Where("id = ? AND status = ? AND locked_at = ?", job.ID, models.ImageJobProcessing, job.LockedAt)
+}
+
+// RequeueImageJobs puts jobs back in the queue that were claimed longer than
+// staleAfter ago and never finished, e.g. because the instance running them
+// stopped. Jobs that already used up their attempts, possibly by crashing
+// the process, are marked failed instead.
+func RequeueImageJobs(staleAfter time.Duration) (int64, error) {
+	stale := config.DB.Model(&models.ImageJob{}).
+		Where("status = ? AND locked_at < ?", models.ImageJobProcessing, time.Now().Add(-staleAfter))
+
+	err := stale.Session(&gorm.Session{}).Where("attempts >= ?", MaxImageJobAttempts).
+		Updates(map[string]interface{}{"status": models.ImageJobFailed, "error": "abandoned by its worker too often"}).Error
+	if err != nil {
+		return 0, err
+	}
+
+	res := stale.Session(&gorm.Session{}).Where("attempts < ?", MaxImageJobAttempts).
+		Updates(map[string]interface{}{"status": models.ImageJobPending, "run_at": time.Now()})
+	return res.RowsAffected, res.Error
+}
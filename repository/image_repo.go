@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"bogbon-api/config"
+	"bogbon-api/models"
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrImageNotFound = errors.New("image not found")
+	ErrImageOrder    = errors.New("image_ids must list every image of the product exactly once")
+	ErrImageMismatch = errors.New("image does not belong to this product")
+)
+
+// preloadImages loads product images in display order with their renditions
+// and alt texts.
+func preloadImages(db *gorm.DB) *gorm.DB {
+	return db.Preload("Images", func(db *gorm.DB) *gorm.DB { return db.Order("position, id") }).
+		Preload("Images.Renditions").
+		Preload("Images.Translations")
+}
+
+// ReorderProductImages shows the images of a product in the order of
+// imageIDs, which must hold each of them once, and makes primaryID the
+// cover. Either may be left empty to keep the current order or cover.
+func ReorderProductImages(productID uint, imageIDs []uint, primaryID *uint) ([]models.ProductImage, error) {
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := lockProduct(tx, productID); err != nil {
+			return err
+		}
+
+		var current []uint
+		if err := tx.Model(&models.ProductImage{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("product_id = ?", productID).Pluck("id", &current).Error; err != nil {
+			return err
+		}
+		owned := make(map[uint]bool, len(current))
+		for _, id := range current {
+			owned[id] = true
+		}
+
+		if len(imageIDs) > 0 {
+			seen := make(map[uint]bool, len(imageIDs))
+			for _, id := range imageIDs {
+				if !owned[id] || seen[id] {
+					return ErrImageOrder
+				}
+				seen[id] = true
+			}
+			if len(seen) != len(current) {
+				return ErrImageOrder
+			}
+			for i, id := range imageIDs {
+				if err := tx.Model(&models.ProductImage{}).Where("id = ?", id).Update("position", i).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		if primaryID != nil {
+			if !owned[*primaryID] {
+				return ErrImageMismatch
+			}
+			if err := tx.Model(&models.ProductImage{}).Where("product_id = ? AND is_primary", productID).
+				Update("is_primary", false).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.ProductImage{}).Where("id = ?", *primaryID).
+				Update("is_primary", true).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var product models.Product
+	if err := config.DB.Scopes(preloadImages).First(&product, productID).Error; err != nil {
+		return nil, err
+	}
+	return product.Images, nil
+}
+
+// SetImageTranslations replaces the alt texts and captions of an image.
+func SetImageTranslations(imageID uint, translations []models.ImageTranslation) (*models.ProductImage, error) {
+	var img models.ProductImage
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.First(&img, imageID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrImageNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("image_id = ?", imageID).Delete(&models.ImageTranslation{}).Error; err != nil {
+			return err
+		}
+		for i := range translations {
+			translations[i].ID = 0
+			translations[i].ImageID = imageID
+		}
+		if len(translations) > 0 {
+			if err := tx.Create(&translations).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = config.DB.Preload("Renditions").Preload("Translations").First(&img, imageID).Error
+	return &img, err
+}
+
+// promotePrimaryImage makes the first remaining image the cover of a product
+// that has none, e.g. after its cover was deleted.
+func promotePrimaryImage(tx *gorm.DB, productID uint) error {
+	return tx.Exec(`UPDATE product_images SET is_primary = TRUE
+		WHERE id = (SELECT id FROM product_images WHERE product_id = ? ORDER BY position, id LIMIT 1)
+		AND NOT EXISTS (SELECT 1 FROM product_images WHERE product_id = ? AND is_primary)`,
+		productID, productID).Error
+}
+
+// EnsureImageOrder gives images from before explicit ordering a position and
+// each product a cover, keeping the old rule of images with "default" in
+// their URL first. It then allows only one cover per product.
+func EnsureImageOrder() error {
+	err := config.DB.Exec(`UPDATE product_images pi SET position = o.pos - 1
+		FROM (SELECT id, ROW_NUMBER() OVER (PARTITION BY product_id ORDER BY (url LIKE '%default%') DESC, id) AS pos
+			FROM product_images
+			WHERE product_id NOT IN (SELECT product_id FROM product_images WHERE is_primary OR position <> 0)) o
+		WHERE pi.id = o.id`).Error
+	if err != nil {
+		return err
+	}
+
+	err = config.DB.Exec(`UPDATE product_images SET is_primary = TRUE
+		WHERE id IN (SELECT DISTINCT ON (product_id) id FROM product_images
+			WHERE product_id NOT IN (SELECT product_id FROM product_images WHERE is_primary)
+			ORDER BY product_id, position, id)`).Error
+	if err != nil {
+		return err
+	}
+
+	return config.DB.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_product_images_primary ON product_images (product_id) WHERE is_primary").Error
+}
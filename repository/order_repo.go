@@ -4,83 +4,493 @@ import (
 	"bogbon-api/config"
 	"bogbon-api/models"
 	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrOrderNotFound = errors.New("order not found")
+	ErrCartEmpty     = errors.New("cart is empty")
 )
 
+// StockShortage describes one cart line that can't be fulfilled.
+type StockShortage struct {
+	ProductID uint  `json:"product_id"`
+	VariantID *uint `json:"variant_id,omitempty"`
+	Requested int   `json:"requested"`
+	Available int   `json:"available"`
+}
+
+// InsufficientStockError is returned when checkout would oversell one or more products.
+type InsufficientStockError struct {
+	Items []StockShortage
+}
+
+func (e *InsufficientStockError) Error() string {
+	parts := make([]string, len(e.Items))
+	for i, s := range e.Items {
+		what := fmt.Sprintf("product %d", s.ProductID)
+		if s.VariantID != nil {
+			what += fmt.Sprintf(" variant %d", *s.VariantID)
+		}
+		parts[i] = fmt.Sprintf("%s (requested %d, available %d)", what, s.Requested, s.Available)
+	}
+	return "insufficient stock for " + strings.Join(parts, ", ")
+}
+
+// InvalidTransitionError is returned when an order cannot move to the requested status.
+type InvalidTransitionError struct {
+	From, To string
+}
+
+func (e *InvalidTransitionError) Error() string {
+	return fmt.Sprintf("cannot change order status from %s to %s", e.From, e.To)
+}
+
 // CreateOrderFromCart creates an Order by copying current Cart items.
+// Checkout runs in a single transaction: the involved product and variant rows are locked,
+// stock is verified and decremented, service slots are reserved, and nothing
+// is written if any item is short. slots optionally picks (or overrides) the
+// booking slot per cart item ID.
 // It returns the newly created Order, with its Items preloaded.
-func CreateOrderFromCart(sessionID string) (*models.Order, error) {
-	// 1) Load the cart and its items
-	var cart models.Cart
-	if err := config.DB.Preload("Items").Where("session_id = ?", sessionID).First(&cart).Error; err != nil {
-		return nil, err
-	}
-	if len(cart.Items) == 0 {
-		return nil, errors.New("cart is empty")
-	}
+func CreateOrderFromCart(sessionID string, delivery models.DeliveryDetails, slots map[uint]time.Time) (*models.Order, error) {
+	var order models.Order
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// 1) Lock the cart, so a second checkout of the same session waits
+		// and then finds it empty, and load its items
+		var cart models.Cart
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("session_id = ?", sessionID).First(&cart).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("cart_id = ?", cart.ID).Order("id").Find(&cart.Items).Error; err != nil {
+			return err
+		}
+		if len(cart.Items) == 0 {
+			return ErrCartEmpty
+		}
+		for i := range cart.Items {
+			if start, ok := slots[cart.Items[i].ID]; ok {
+				cart.Items[i].SlotStart = &start
+			}
+		}
 
-	// 2) Create the order record
-	order := models.Order{
-		SessionID: sessionID,
-		CartID:    cart.ID,
-		IsPaid:    false,
-	}
-	if err := config.DB.Create(&order).Error; err != nil {
-		return nil, err
-	}
+		// 2) Lock the products, then their variants, in a stable order so
+		// concurrent checkouts can't deadlock. Lines with a variant draw on the
+		// variant's stock, the others on the product's.
+		requested := make(map[uint]int)
+		requestedVariant := make(map[uint]int)
+		requestedTotal := make(map[uint]int)
+		ids := make([]uint, 0, len(cart.Items))
+		seen := make(map[uint]bool)
+		for _, ci := range cart.Items {
+			if !seen[ci.ProductID] {
+				seen[ci.ProductID] = true
+				ids = append(ids, ci.ProductID)
+			}
+			requestedTotal[ci.ProductID] += ci.Quantity
+			if ci.VariantID != nil {
+				requestedVariant[*ci.VariantID] += ci.Quantity
+			} else {
+				requested[ci.ProductID] += ci.Quantity
+			}
+		}
+		var products []models.Product
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id IN ?", ids).Order("id").
+			Find(&products).Error; err != nil {
+			return err
+		}
+		byID := make(map[uint]*models.Product, len(products))
+		for i := range products {
+			byID[products[i].ID] = &products[i]
+		}
+		var variants []models.ProductVariant
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Options").
+			Where("product_id IN ?", ids).Order("id").
+			Find(&variants).Error; err != nil {
+			return err
+		}
+		variantByID := make(map[uint]*models.ProductVariant, len(variants))
+		hasVariants := make(map[uint]bool)
+		for i := range variants {
+			variantByID[variants[i].ID] = &variants[i]
+			hasVariants[variants[i].ProductID] = true
+		}
 
-	// 3) Copy cart items into order_items
-	for _, ci := range cart.Items {
-		oi := models.OrderItem{
-			OrderID:   order.ID,
-			ProductID: ci.ProductID,
-			Quantity:  ci.Quantity,
+		// 3) Verify variants and stock for every item before touching anything
+		var shortages []StockShortage
+		for _, ci := range cart.Items {
+			if ci.VariantID != nil {
+				v, ok := variantByID[*ci.VariantID]
+				if !ok || v.ProductID != ci.ProductID {
+					return &VariantError{CartItemID: ci.ID, Err: ErrVariantNotFound}
+				}
+			} else if hasVariants[ci.ProductID] {
+				return &VariantError{CartItemID: ci.ID, Err: ErrVariantRequired}
+			}
 		}
-		if err := config.DB.Create(&oi).Error; err != nil {
-			return nil, err
+		for _, id := range ids {
+			p, ok := byID[id]
+			if !ok {
+				// Deleted since it was added to the cart: nothing is available
+				shortages = append(shortages, StockShortage{ProductID: id, Requested: requestedTotal[id]})
+				continue
+			}
+			if p.Type != models.ProductTypeService && requested[id] > 0 && p.Stock < requested[id] {
+				shortages = append(shortages, StockShortage{ProductID: id, Requested: requested[id], Available: p.Stock})
+			}
+		}
+		for _, v := range variants {
+			want := requestedVariant[v.ID]
+			if byID[v.ProductID] == nil {
+				continue // the product itself is already reported above
+			}
+			if want > 0 && byID[v.ProductID].Type != models.ProductTypeService && v.Stock < want {
+				variantID := v.ID
+				shortages = append(shortages, StockShortage{ProductID: v.ProductID, VariantID: &variantID, Requested: want, Available: v.Stock})
+			}
+		}
+		if len(shortages) > 0 {
+			return &InsufficientStockError{Items: shortages}
+		}
+
+		// 4) Check booking slots of service items; the product lock serialises bookings per service
+		slotEnds := make(map[uint]time.Time)
+		pending := make(map[string]int)
+		for _, ci := range cart.Items {
+			if byID[ci.ProductID].Type != models.ProductTypeService {
+				continue
+			}
+			scheduled, err := hasSchedule(tx, ci.ProductID)
+			if err != nil {
+				return err
+			}
+			if !scheduled {
+				continue
+			}
+			if ci.SlotStart == nil {
+				return &SlotError{CartItemID: ci.ID, Err: ErrSlotRequired}
+			}
+			key := fmt.Sprintf("%d@%d", ci.ProductID, ci.SlotStart.Unix())
+			end, err := reserveCheck(tx, ci.ProductID, *ci.SlotStart, pending[key], ci.Quantity)
+			if err != nil {
+				return &SlotError{CartItemID: ci.ID, Err: err}
+			}
+			pending[key] += ci.Quantity
+			slotEnds[ci.ID] = end
+		}
+
+		// 5) Decrement stock
+		for _, id := range ids {
+			if byID[id].Type == models.ProductTypeService || requested[id] == 0 {
+				continue
+			}
+			if err := tx.Model(&models.Product{}).Where("id = ?", id).
+				Update("stock", gorm.Expr("stock - ?", requested[id])).Error; err != nil {
+				return err
+			}
+		}
+		for id, quantity := range requestedVariant {
+			if byID[variantByID[id].ProductID].Type == models.ProductTypeService {
+				continue
+			}
+			if err := tx.Model(&models.ProductVariant{}).Where("id = ?", id).
+				Update("stock", gorm.Expr("stock - ?", quantity)).Error; err != nil {
+				return err
+			}
+		}
+
+		// 6) Snapshot prices and names into order items
+		var translations []models.ProductTranslation
+		if err := tx.Where("product_id IN ?", ids).Find(&translations).Error; err != nil {
+			return err
+		}
+		items := make([]models.OrderItem, 0, len(cart.Items))
+		subtotal := 0
+		for _, ci := range cart.Items {
+			p := byID[ci.ProductID]
+			oi := models.OrderItem{
+				ProductID:   ci.ProductID,
+				Quantity:    ci.Quantity,
+				UnitPrice:   p.Price,
+				ProductType: p.Type,
+			}
+			if ci.VariantID != nil {
+				v := variantByID[*ci.VariantID]
+				oi.VariantID = &v.ID
+				oi.SKU = v.SKU
+				oi.VariantLabel = v.Label()
+				oi.UnitPrice = v.Price
+			}
+			oi.LineTotal = oi.UnitPrice * ci.Quantity
+			if end, ok := slotEnds[ci.ID]; ok {
+				start := ci.SlotStart.In(config.Location)
+				oi.SlotStart = &start
+				oi.SlotEnd = &end
+			}
+			for _, t := range translations {
+				if t.ProductID == p.ID {
+					oi.Translations = append(oi.Translations, models.OrderItemTranslation{
+						LanguageCode: t.LanguageCode,
+						Name:         t.Name,
+					})
+				}
+			}
+			subtotal += oi.LineTotal
+			items = append(items, oi)
+		}
+
+		// 7) Create the order record, linked to the account when the session belongs to one
+		order = models.Order{
+			SessionID: sessionID,
+			CartID:    cart.ID,
+			Status:    models.OrderPending,
+			Subtotal:  subtotal,
+			Total:     subtotal,
+			Items:     items,
+			StatusHistory: []models.OrderStatusChange{
+				{ToStatus: models.OrderPending},
+			},
+			DeliveryDetails: delivery,
+		}
+		var customer models.Customer
+		if err := tx.Where("session_id = ?", sessionID).Limit(1).Find(&customer).Error; err != nil {
+			return err
+		}
+		if customer.ID != 0 {
+			order.CustomerID = &customer.ID
+		}
+		if err := tx.Create(&order).Error; err != nil {
+			return err
+		}
+
+		// 8) Reserve the booked slots
+		for _, oi := range order.Items {
+			if oi.SlotStart == nil {
+				continue
+			}
+			if err := tx.Create(&models.SlotReservation{
+				ProductID:   oi.ProductID,
+				StartsAt:    *oi.SlotStart,
+				EndsAt:      *oi.SlotEnd,
+				OrderID:     order.ID,
+				OrderItemID: oi.ID,
+				Quantity:    oi.Quantity,
+			}).Error; err != nil {
+				return err
+			}
 		}
-	}
 
-	// 4) Clear the cart
-	if err := ClearCart(sessionID); err != nil {
+		// 9) Clear the cart
+		return tx.Where("cart_id = ?", cart.ID).Delete(&models.CartItem{}).Error
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	// 5) Reload order with its items
-	if err := config.DB.Preload("Items.Product").First(&order, order.ID).Error; err != nil {
+	// 10) Reload order with its items
+	if err := config.DB.Scopes(withOrderDetails).First(&order, order.ID).Error; err != nil {
 		return nil, err
 	}
 
 	return &order, nil
 }
 
+// withOrderDetails preloads everything an order response needs.
+func withOrderDetails(db *gorm.DB) *gorm.DB {
+	return db.Preload("Items.Product.Translations").Preload("Items.Translations").Preload("StatusHistory")
+}
+
 // GetOrderBySession returns the most recent order for a session.
 func GetOrderBySession(sessionID string) (*models.Order, error) {
 	var order models.Order
-	err := config.DB.Preload("Items.Product").
+	err := config.DB.Scopes(withOrderDetails).
 		Where("session_id = ?", sessionID).
 		Order("created_at DESC").
 		First(&order).Error
 	return &order, err
 }
 
-// GetAllOrders returns every order in the system (with items).
-func GetAllOrders() ([]models.Order, error) {
+// GetAllOrders returns one page of all orders in the system (with items),
+// newest first unless p.Sort says otherwise.
+func GetAllOrders(p PageParams) ([]models.Order, PageMeta, error) {
+	sortName, opt, desc, err := resolveSort(p, "-created_at", map[string]sortOption{
+		"created_at": {expr: "orders.created_at", sqlType: "timestamptz"},
+		"total":      {expr: "orders.total", sqlType: "bigint"},
+	})
+	if err != nil {
+		return nil, PageMeta{}, err
+	}
+
+	ids, meta, err := paginate(config.DB.Model(&models.Order{}), "orders.id", p, sortName, opt, desc)
+	if err != nil {
+		return nil, meta, err
+	}
+
 	var orders []models.Order
-	err := config.DB.Preload("Items.Product").Find(&orders).Error
-	return orders, err
+	if len(ids) > 0 {
+		if err := config.DB.Scopes(withOrderDetails).Where("id IN ?", ids).Find(&orders).Error; err != nil {
+			return nil, meta, err
+		}
+	}
+	return inIDOrder(orders, ids, func(o models.Order) uint { return o.ID }), meta, nil
+}
+
+// GetOrderByID returns an order with its items and status history.
+func GetOrderByID(id uint) (*models.Order, error) {
+	var order models.Order
+	err := config.DB.Scopes(withOrderDetails).First(&order, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// TransitionOrder moves an order to a new status if the transition is allowed,
+// recording the change in the order's status history.
+func TransitionOrder(orderID uint, to string, adminID *uint, note string) (*models.Order, error) {
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		return transitionOrder(tx, orderID, to, adminID, note)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return GetOrderByID(orderID)
+}
+
+// transitionOrder is TransitionOrder inside the caller's transaction.
+func transitionOrder(tx *gorm.DB, orderID uint, to string, adminID *uint, note string) error {
+	var order models.Order
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, orderID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrOrderNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if !models.CanTransition(order.Status, to) {
+		return &InvalidTransitionError{From: order.Status, To: to}
+	}
+
+	if err := tx.Model(&order).Update("status", to).Error; err != nil {
+		return err
+	}
+	if to == models.OrderCancelled {
+		if err := restockOrder(tx, order.ID); err != nil {
+			return err
+		}
+	}
+	// Cancelled and refunded orders no longer take up booked slots or crews
+	if to == models.OrderCancelled || to == models.OrderRefunded {
+		if err := releaseSlots(tx, order.ID); err != nil {
+			return err
+		}
+		if err := tx.Where("order_id = ?", order.ID).Delete(&models.CrewAssignment{}).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Create(&models.OrderStatusChange{
+		OrderID:    order.ID,
+		FromStatus: order.Status,
+		ToStatus:   to,
+		AdminID:    adminID,
+		Note:       note,
+	}).Error
+}
+
+// restockOrder returns the reserved quantities of a cancelled order to stock.
+func restockOrder(tx *gorm.DB, orderID uint) error {
+	var items []models.OrderItem
+	if err := tx.Where("order_id = ? AND product_type <> ?", orderID, models.ProductTypeService).
+		Find(&items).Error; err != nil {
+		return err
+	}
+	for _, item := range items {
+		// A variant that has since been deleted has no stock to return to
+		if item.VariantID != nil {
+			if err := tx.Model(&models.ProductVariant{}).Where("id = ?", *item.VariantID).
+				Update("stock", gorm.Expr("stock + ?", item.Quantity)).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		if err := tx.Model(&models.Product{}).Unscoped().Where("id = ?", item.ProductID).
+			Update("stock", gorm.Expr("stock + ?", item.Quantity)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MigrateLegacyPaidFlag converts the old is_paid column into order statuses
+// and drops it. It is a no-op once the column is gone.
+func MigrateLegacyPaidFlag() error {
+	if !config.DB.Migrator().HasColumn("orders", "is_paid") {
+		return nil
+	}
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("UPDATE orders SET status = ? WHERE is_paid", models.OrderPaid).Error; err != nil {
+			return err
+		}
+		return tx.Migrator().DropColumn("orders", "is_paid")
+	})
 }
 
-// UpdateOrder marks an order as paid/unpaid.
-func UpdateOrder(order *models.Order) error {
-	return config.DB.Model(&models.Order{}).
-		Where("id = ?", order.ID).
-		Update("is_paid", order.IsPaid).
-		Error
+// MigrateOrderTotals fills the price snapshots of orders placed before
+// orders kept them, pricing their items at the current product or variant
+// price. Orders that already have totals are left alone.
+func MigrateOrderTotals() error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		legacy := "SELECT id FROM orders WHERE subtotal = 0 AND total = 0"
+		err := tx.Exec(`UPDATE order_items oi SET
+				unit_price = COALESCE((SELECT price FROM product_variants WHERE id = oi.variant_id),
+					(SELECT price FROM products WHERE id = oi.product_id), 0),
+				product_type = COALESCE(NULLIF(oi.product_type, ''), (SELECT type FROM products WHERE id = oi.product_id))
+			WHERE oi.unit_price = 0 AND oi.order_id IN (` + legacy + `)`).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Exec(`UPDATE order_items SET line_total = unit_price * quantity
+			WHERE line_total = 0 AND order_id IN (` + legacy + `)`).Error
+		if err != nil {
+			return err
+		}
+		return tx.Exec(`UPDATE orders o SET subtotal = s.sum, total = s.sum
+			FROM (SELECT order_id, SUM(line_total) AS sum FROM order_items GROUP BY order_id) s
+			WHERE s.order_id = o.id AND o.subtotal = 0 AND o.total = 0`).Error
+	})
 }
 
-// DeleteOrderBySession deletes all orders for the given session.
-func DeleteOrderBySession(sessionID string) error {
-	return config.DB.Where("session_id = ?", sessionID).
-		Delete(&models.Order{}).Error
+// CancelPendingOrdersBySession cancels every pending order of a session the
+// way TransitionOrder does, returning stock and slots, and reports how many
+// it cancelled. Orders past pending are left to the admins.
+func CancelPendingOrdersBySession(sessionID string) (int, error) {
+	var ids []uint
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Order{}).
+			Where("session_id = ? AND status = ?", sessionID, models.OrderPending).
+			Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if err := transitionOrder(tx, id, models.OrderCancelled, nil, "cancelled by customer"); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(ids), nil
 }
 
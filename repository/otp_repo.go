@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"bogbon-api/config"
+	"bogbon-api/models"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	otpTTL          = 5 * time.Minute
+	otpResendDelay  = time.Minute
+	otpWindow       = time.Hour
+	otpMaxPerWindow = 5
+	otpMaxAttempts  = 5
+)
+
+var (
+	ErrOTPRateLimited     = errors.New("too many codes requested, try again later")
+	ErrOTPExpired         = errors.New("code expired or not requested")
+	ErrOTPInvalid         = errors.New("invalid code")
+	ErrOTPTooManyAttempts = errors.New("too many wrong attempts, request a new code")
+)
+
+// CreateOTP issues a new 6-digit code for the phone, invalidating older ones.
+// It enforces a resend delay and a per-hour limit per phone number. Requests
+// for the same phone are serialized, so parallel ones cannot all pass the limit.
+func CreateOTP(phone string) (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	code := fmt.Sprintf("%06d", n.Int64())
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		// Held until the transaction ends; there may be no row to lock yet
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "otp:"+phone).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		var recent []models.OTPCode
+		if err := tx.Where("phone = ? AND created_at > ?", phone, now.Add(-otpWindow)).
+			Order("created_at DESC").Find(&recent).Error; err != nil {
+			return err
+		}
+		if len(recent) >= otpMaxPerWindow ||
+			(len(recent) > 0 && now.Sub(recent[0].CreatedAt) < otpResendDelay) {
+			return ErrOTPRateLimited
+		}
+
+		if err := tx.Model(&models.OTPCode{}).
+			Where("phone = ? AND consumed_at IS NULL", phone).
+			Update("consumed_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.OTPCode{
+			Phone:     phone,
+			CodeHash:  hashOTP(phone, code),
+			ExpiresAt: now.Add(otpTTL),
+		}).Error
+	})
+	if err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// VerifyOTP checks the code against the phone's latest active code and
+// consumes it on success. Wrong guesses count towards the attempt limit.
+func VerifyOTP(phone, code string) error {
+	var result error
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var otp models.OTPCode
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("phone = ? AND consumed_at IS NULL", phone).
+			Order("created_at DESC").
+			First(&otp).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			result = ErrOTPExpired
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		switch {
+		case now.After(otp.ExpiresAt):
+			result = ErrOTPExpired
+			return nil
+		case otp.Attempts >= otpMaxAttempts:
+			result = ErrOTPTooManyAttempts
+			return nil
+		}
+
+		if subtle.ConstantTimeCompare([]byte(otp.CodeHash), []byte(hashOTP(phone, code))) != 1 {
+			result = ErrOTPInvalid
+			return tx.Model(&otp).Update("attempts", otp.Attempts+1).Error
+		}
+		return tx.Model(&otp).Update("consumed_at", now).Error
+	})
+	if err != nil {
+		return err
+	}
+	return result
+}
+
+// FindOrCreateCustomerByPhone returns the customer with this phone, creating a
+// password-less account on first login.
+func FindOrCreateCustomerByPhone(phone string) (*models.Customer, error) {
+	customer := models.Customer{Phone: &phone}
+	err := config.DB.Where("phone = ?", phone).
+		Attrs(models.Customer{SessionID: uuid.NewString()}).
+		FirstOrCreate(&customer).Error
+	if err != nil {
+		return nil, err
+	}
+	return &customer, nil
+}
+
+func hashOTP(phone, code string) string {
+	sum := sha256.Sum256([]byte(phone + ":" + code + ":" + os.Getenv("SESSION_SECRET")))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidSort   = errors.New("invalid sort")
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// PageParams selects one page of a list. When Cursor is set it takes
+// precedence over Page and the list continues right after the cursor's row.
+type PageParams struct {
+	Page    int
+	PerPage int
+	Cursor  string
+	Sort    string // e.g. "price" or "-price"; empty means the list's default order
+}
+
+// PageMeta describes the page that was returned.
+type PageMeta struct {
+	Total      int64  `json:"total"`
+	Page       int    `json:"page,omitempty"`
+	PerPage    int    `json:"per_page"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// sortOption is one allowed ?sort= value of a list.
+type sortOption struct {
+	expr    string // SQL expression, may contain ? placeholders
+	vars    []interface{}
+	sqlType string // type the cursor's text value is cast back to
+}
+
+// cursor is the decoded form of PageMeta.NextCursor: the sort value and ID of
+// the last row of the previous page.
+type cursor struct {
+	Sort string `json:"s"`
+	Key  string `json:"k"`
+	ID   uint   `json:"id"`
+}
+
+func encodeCursor(c cursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeCursor parses s, which must have been made for sort, and checks that
+// its key can be cast back to sqlType, so a tampered cursor is rejected here
+// instead of failing in SQL.
+func decodeCursor(s, sort, sqlType string) (cursor, error) {
+	var c cursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || json.Unmarshal(b, &c) != nil || c.Sort != sort || !validCursorKey(c.Key, sqlType) {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// validCursorKey reports whether key is the text form of a sqlType value, as
+// Postgres casts it to text.
+func validCursorKey(key, sqlType string) bool {
+	switch sqlType {
+	case "bigint":
+		_, err := strconv.ParseInt(key, 10, 64)
+		return err == nil
+	case "double precision":
+		_, err := strconv.ParseFloat(key, 64)
+		return err == nil
+	case "timestamptz":
+		for _, layout := range []string{"2006-01-02 15:04:05-07", "2006-01-02 15:04:05-07:00"} {
+			if _, err := time.Parse(layout, key); err == nil {
+				return true
+			}
+		}
+		return false
+	default:
+		return true
+	}
+}
+
+// resolveSort looks up p.Sort (or def when empty) in options. A leading "-"
+// sorts descending.
+func resolveSort(p PageParams, def string, options map[string]sortOption) (string, sortOption, bool, error) {
+	name := p.Sort
+	if name == "" {
+		name = def
+	}
+	desc := strings.HasPrefix(name, "-")
+	opt, ok := options[strings.TrimPrefix(name, "-")]
+	if !ok {
+		return "", sortOption{}, false, ErrInvalidSort
+	}
+	return name, opt, desc, nil
+}
+
+// paginate counts the rows matched by q, then returns the IDs of the requested
+// page in sort order. Only IDs and sort keys are read here so that callers can
+// load the rows with whatever preloads they need. Ties are broken by ID, which
+// keeps keyset pagination stable.
+func paginate(q *gorm.DB, idColumn string, p PageParams, sortName string, opt sortOption, desc bool) ([]uint, PageMeta, error) {
+	meta := PageMeta{PerPage: p.PerPage}
+	if err := q.Session(&gorm.Session{}).Distinct(idColumn).Count(&meta.Total).Error; err != nil {
+		return nil, meta, err
+	}
+
+	q = q.Session(&gorm.Session{})
+	if p.Cursor != "" {
+		c, err := decodeCursor(p.Cursor, sortName, opt.sqlType)
+		if err != nil {
+			return nil, meta, err
+		}
+		op := ">"
+		if desc {
+			op = "<"
+		}
+		q = q.Where("("+opt.expr+", "+idColumn+") "+op+" (CAST(? AS "+opt.sqlType+"), ?)",
+			append(append([]interface{}{}, opt.vars...), c.Key, c.ID)...)
+	} else {
+		meta.Page = max(p.Page, 1)
+		q = q.Offset((meta.Page - 1) * p.PerPage)
+	}
+
+	var rows []struct {
+		ID      uint
+		SortKey string
+	}
+	dir := " ASC"
+	if desc {
+		dir = " DESC"
+	}
+	err := q.Select(idColumn+" AS id, CAST(("+opt.expr+") AS text) AS sort_key", opt.vars...).
+		Order(clause.OrderBy{Expression: clause.Expr{SQL: "(" + opt.expr + ")" + dir + ", " + idColumn + dir, Vars: opt.vars}}).
+		Limit(p.PerPage + 1).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, meta, err
+	}
+
+	if len(rows) > p.PerPage {
+		rows = rows[:p.PerPage]
+		last := rows[len(rows)-1]
+		meta.NextCursor = encodeCursor(cursor{Sort: sortName, Key: last.SortKey, ID: last.ID})
+	}
+	ids := make([]uint, len(rows))
+	for i, r := range rows {
+		ids[i] = r.ID
+	}
+	return ids, meta, nil
+}
+
+// inIDOrder reorders rows loaded with "id IN ?" to match ids.
+func inIDOrder[T any](rows []T, ids []uint, id func(T) uint) []T {
+	byID := make(map[uint]T, len(rows))
+	for _, r := range rows {
+		byID[id(r)] = r
+	}
+	out := make([]T, 0, len(ids))
+	for _, v := range ids {
+		if r, ok := byID[v]; ok {
+			out = append(out, r)
+		}
+	}
+	return out
+}
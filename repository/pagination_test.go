@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"encoding/base64"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		c       cursor
+		sqlType string
+	}{
+		{cursor{Sort: "-id", Key: "42", ID: 42}, "bigint"},
+		{cursor{Sort: "price", Key: "129000.5", ID: 7}, "double precision"},
+		{cursor{Sort: "-created_at", Key: "2026-03-02 10:15:00.123456+05", ID: 3}, "timestamptz"},
+		{cursor{Sort: "name", Key: "Oʻrik, \"red\"", ID: 9}, "text"},
+	}
+	for _, tt := range tests {
+		got, err := decodeCursor(encodeCursor(tt.c), tt.c.Sort, tt.sqlType)
+		if err != nil || got != tt.c {
+			t.Errorf("decodeCursor(encodeCursor(%+v)) = %+v, %v", tt.c, got, err)
+		}
+	}
+}
+
+func TestDecodeCursorRejects(t *testing.T) {
+	valid := encodeCursor(cursor{Sort: "price", Key: "100", ID: 1})
+	tests := []struct {
+		name, s, sort, sqlType string
+	}{
+		{"other sort", valid, "-price", "double precision"},
+		{"not base64", "!!!", "price", "double precision"},
+		{"not json", base64.RawURLEncoding.EncodeToString([]byte("price:100")), "price", "double precision"},
+		{"empty", "", "price", "double precision"},
+		{"tampered bigint", encodeCursor(cursor{Sort: "id", Key: "1 OR 1=1", ID: 1}), "id", "bigint"},
+		{"tampered double", encodeCursor(cursor{Sort: "price", Key: "cheap", ID: 1}), "price", "double precision"},
+		{"tampered timestamp", encodeCursor(cursor{Sort: "created_at", Key: "yesterday", ID: 1}), "created_at", "timestamptz"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeCursor(tt.s, tt.sort, tt.sqlType); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("decodeCursor = %v, want ErrInvalidCursor", err)
+			}
+		})
+	}
+}
+
+func TestValidCursorKey(t *testing.T) {
+	tests := []struct {
+		key, sqlType string
+		want         bool
+	}{
+		{"42", "bigint", true},
+		{"-7", "bigint", true},
+		{"4.2", "bigint", false},
+		{"", "bigint", false},
+		{"1 OR 1", "bigint", false},
+		{"129000", "double precision", true},
+		{"1.5e3", "double precision", true},
+		{"1,5", "double precision", false},
+		{"2026-03-02 10:15:00+05", "timestamptz", true},
+		{"2026-03-02 10:15:00.5+05:30", "timestamptz", true},
+		{"2026-03-02", "timestamptz", false},
+		{"2026-03-02T10:15:00Z", "timestamptz", false},
+		{"anything at all", "text", true},
+		{"", "text", true},
+	}
+	for _, tt := range tests {
+		if got := validCursorKey(tt.key, tt.sqlType); got != tt.want {
+			t.Errorf("validCursorKey(%q, %q) = %v, want %v", tt.key, tt.sqlType, got, tt.want)
+		}
+	}
+}
+
+func TestResolveSort(t *testing.T) {
+	options := map[string]sortOption{
+		"id":    {expr: "products.id", sqlType: "bigint"},
+		"price": {expr: "price", sqlType: "double precision"},
+	}
+	tests := []struct {
+		sort, wantName string
+		wantDesc       bool
+		wantErr        error
+	}{
+		{"", "-id", true, nil},
+		{"price", "price", false, nil},
+		{"-price", "-price", true, nil},
+		{"name", "", false, ErrInvalidSort},
+		{"--price", "", false, ErrInvalidSort},
+	}
+	for _, tt := range tests {
+		name, _, desc, err := resolveSort(PageParams{Sort: tt.sort}, "-id", options)
+		if name != tt.wantName || desc != tt.wantDesc || !errors.Is(err, tt.wantErr) {
+			t.Errorf("resolveSort(%q) = %q, %v, %v, want %q, %v, %v", tt.sort, name, desc, err, tt.wantName, tt.wantDesc, tt.wantErr)
+		}
+	}
+}
+
+func TestInIDOrder(t *testing.T) {
+	rows := []uint{5, 1, 3}
+	got := inIDOrder(rows, []uint{3, 4, 5, 1}, func(r uint) uint { return r })
+	if want := []uint{3, 5, 1}; !slices.Equal(got, want) {
+		t.Errorf("inIDOrder = %v, want %v", got, want)
+	}
+}
@@ -3,10 +3,13 @@ package repository
 import (
 	"bogbon-api/config"
 	"bogbon-api/models"
+	"bogbon-api/search"
+	"bogbon-api/storage"
 	"errors"
-	"os"
+	"strings"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // CreateProduct creates a product and its translations
@@ -22,18 +25,25 @@ func CreateProduct(p *models.Product, translations map[string]struct {
 
 	// Now create translations for the product
 	for lang, translation := range translations {
+		slug, err := uniqueSlug(config.DB, models.SlugProduct, p.ID, lang, translation.Name)
+		if err != nil {
+			return nil, err
+		}
 		translationRecord := models.ProductTranslation{
 			ProductID:    p.ID,
 			LanguageCode: lang,
 			Name:         translation.Name,
 			Description:  translation.Description,
 			ShortInfo:    translation.ShortInfo,
+			Slug:         slug,
 		}
 		if err := config.DB.Create(&translationRecord).Error; err != nil {
 			return nil, err
 		}
 	}
 
+	search.Suggestions.Invalidate()
+
 	// Reload product with translations
 	if err := config.DB.Preload("Categories").Preload("Translations").First(p, p.ID).Error; err != nil {
 		return nil, err
@@ -56,7 +66,10 @@ func GetProductByID(id uint) (*models.Product, error) {
 		Preload("Categories.Translations").
 		Preload("Categories").
 		Preload("Translations").
-		Preload("Images", "is_original = ?", true).First(&p, id).Error
+		Preload("Variants.Options").
+		Preload("Attributes.Attribute.Translations").
+		Preload("Attributes.Value.Translations").
+		Scopes(preloadImages).First(&p, id).Error
 
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		return nil, errors.New("product not found")
@@ -69,34 +82,126 @@ func GetProductByID(id uint) (*models.Product, error) {
 	return &p, nil
 }
 
-// UpdateProductImage adds a new image for a product
-func UpdateProductImage(productID uint, imagePath string, isOriginal bool) error {
-	// Check if the product exists
+// ProductExists reports whether a product with id exists.
+func ProductExists(id uint) (bool, error) {
+	return productExists(config.DB, id)
+}
+
+func productExists(tx *gorm.DB, id uint) (bool, error) {
+	var count int64
+	err := tx.Model(&models.Product{}).Where("id = ?", id).Count(&count).Error
+	return count > 0, err
+}
+
+// lockProduct loads a product and locks its row until tx ends. Changes to
+// the images of a product take this lock first, so concurrent uploads,
+// reorders and deletions cannot both pick the same position or cover.
+func lockProduct(tx *gorm.DB, id uint) (*models.Product, error) {
 	var product models.Product
-	if err := config.DB.First(&product, productID).Error; err != nil {
-		return err
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrProductNotFound
+	}
+	if err != nil {
+		return nil, err
 	}
+	return &product, nil
+}
 
-	// Build full URL
-	baseURL := os.Getenv("BASE_URL")
-	fullImageURL := baseURL + "/" + imagePath
+// addProductImage adds a new image for a product. key is where
+// storage.Files keeps the uploaded original; renditions are its resized
+// copies, already stored. URLs come from the storage driver.
+func addProductImage(tx *gorm.DB, productID uint, key string, renditions []models.ImageRendition) (*models.ProductImage, error) {
+	product, err := lockProduct(tx, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	// New images go last; the first one becomes the cover
+	var last struct {
+		Count    int64
+		Position int
+	}
+	if err := tx.Model(&models.ProductImage{}).Where("product_id = ?", productID).
+		Select("COUNT(*) AS count, COALESCE(MAX(position), -1) AS position").
+		Scan(&last).Error; err != nil {
+		return nil, err
+	}
 
-	// Create new ProductImage
 	newImage := models.ProductImage{
 		ProductID:  product.ID,
-		URL:        fullImageURL,
-		IsOriginal: isOriginal,
+		URL:        storage.Files.URL(key),
+		StorageKey: key,
+		IsOriginal: true,
+		Position:   last.Position + 1,
+		IsPrimary:  last.Count == 0,
+		Renditions: renditions,
+	}
+	for i := range newImage.Renditions {
+		newImage.Renditions[i].URL = storage.Files.URL(newImage.Renditions[i].StorageKey)
 	}
 
-	// Save the new image to the database
-	if err := config.DB.Create(&newImage).Error; err != nil {
+	// Save the image together with its renditions
+	if err := tx.Create(&newImage).Error; err != nil {
+		return nil, err
+	}
+
+	return &newImage, nil
+}
+
+// MigrateImageKeys fills in the storage key of images uploaded before
+// pluggable storage, whose URLs point at BASE_URL/uploads/<key>.
+func MigrateImageKeys() error {
+	return config.DB.Exec(`UPDATE product_images
+		SET storage_key = substring(url FROM position('/uploads/' IN url) + length('/uploads/'))
+		WHERE COALESCE(storage_key, '') = '' AND position('/uploads/' IN url) > 0`).Error
+}
+
+// MigrateImageRenditions folds the legacy image pairs, a 305px-high thumbnail
+// in min_uploads and the full image in max_uploads stored as two rows, into
+// one image: the full one, with the thumbnail as its "card" rendition.
+// Thumbnails without a full image stay as they are.
+func MigrateImageRenditions() error {
+	var thumbs []models.ProductImage
+	if err := config.DB.Where("is_original = ? AND storage_key LIKE ?", false, "min_uploads/%").
+		Find(&thumbs).Error; err != nil {
 		return err
 	}
 
+	for _, thumb := range thumbs {
+		name := strings.TrimPrefix(thumb.StorageKey, "min_uploads/")
+		err := config.DB.Transaction(func(tx *gorm.DB) error {
+			var full models.ProductImage
+			err := tx.Where("product_id = ? AND is_original = ? AND storage_key = ?", thumb.ProductID, true, "max_uploads/"+name).
+				First(&full).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			card := models.ImageRendition{ImageID: full.ID, Name: "card", Height: 305, URL: thumb.URL, StorageKey: thumb.StorageKey}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&card).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&thumb).Error
+		})
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 func CreateTranslation(translation *models.ProductTranslation) error {
+	if translation.Slug == "" {
+		slug, err := uniqueSlug(config.DB, models.SlugProduct, translation.ProductID, translation.LanguageCode, translation.Name)
+		if err != nil {
+			return err
+		}
+		translation.Slug = slug
+	}
 	return config.DB.Create(translation).Error
 }
 
@@ -141,6 +246,13 @@ func UpdateProduct(product *models.Product, translations map[string]struct {
 		return err
 	}
 
+	// Remember the current slugs so renamed products keep their old URLs
+	oldSlugs, err := currentSlugs(tx, models.SlugProduct, product.ID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	// Remove existing translations
 	if err := tx.Where("product_id = ?", product.ID).Delete(&models.ProductTranslation{}).Error; err != nil {
 		tx.Rollback()
@@ -148,21 +260,38 @@ func UpdateProduct(product *models.Product, translations map[string]struct {
 	}
 
 	// Add new translations
+	newSlugs := make(map[string]string, len(translations))
 	for lang, t := range translations {
+		slug, err := uniqueSlug(tx, models.SlugProduct, product.ID, lang, t.Name)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
 		translation := models.ProductTranslation{
 			ProductID:    product.ID,
 			LanguageCode: lang,
 			Name:         t.Name,
 			Description:  t.Description,
+			Slug:         slug,
 		}
 		if err := tx.Create(&translation).Error; err != nil {
 			tx.Rollback()
 			return err
 		}
+		newSlugs[lang] = slug
+	}
+
+	if err := recordSlugChanges(tx, models.SlugProduct, product.ID, oldSlugs, newSlugs); err != nil {
+		tx.Rollback()
+		return err
 	}
 
 	// Commit the transaction
-	return tx.Commit().Error
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+	search.Suggestions.Invalidate()
+	return nil
 }
 
 func DeleteProduct(id uint) error {
@@ -174,6 +303,39 @@ func DeleteProduct(id uint) error {
 		return err
 	}
 
+	// Old slugs of a deleted product have nothing left to point to
+	if err := tx.Where("kind = ? AND target_id = ?", models.SlugProduct, id).Delete(&models.SlugRedirect{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Nobody can buy it any more: drop it from carts, along with its variants.
+	// Order items keep their SKU and label snapshot.
+	if err := tx.Where("product_id = ?", id).Delete(&models.CartItem{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Where("product_id = ?", id).Delete(&models.ProductVariant{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// The product is only soft-deleted, but its images go for good
+	var imageIDs []uint
+	if err := tx.Model(&models.ProductImage{}).Where("product_id = ?", id).Pluck("id", &imageIDs).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	keys, err := imageKeys(tx, imageIDs)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Where("product_id = ?", id).Delete(&models.ProductImage{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	// Delete the product
 	if err := tx.Delete(&models.Product{}, id).Error; err != nil {
 		tx.Rollback()
@@ -181,22 +343,46 @@ func DeleteProduct(id uint) error {
 	}
 
 	// Commit the transaction
-	return tx.Commit().Error
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+	deleteStoredFiles(keys)
+	search.Suggestions.Invalidate()
+	return nil
 }
 
+// DeleteProductImage removes an image and the stored files of its original
+// and renditions. When it was the cover, the next image takes its place.
 func DeleteProductImage(imageID uint) error {
-	var img models.ProductImage
-	err := config.DB.First(&img, imageID).Error
-	if err != nil {
+	var keys []string
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var img models.ProductImage
+		err := tx.First(&img, imageID).Error
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return errors.New("image not found")
+			return ErrImageNotFound
+		}
+		if err != nil {
+			return err
+		}
+		// Images of deleted products can still be removed
+		if _, err := lockProduct(tx, img.ProductID); err != nil && !errors.Is(err, ErrProductNotFound) {
+			return err
+		}
+		if keys, err = imageKeys(tx, []uint{img.ID}); err != nil {
+			return err
 		}
-		return err
-	}
 
-	if err := config.DB.Delete(&img).Error; err != nil {
+		if err := tx.Delete(&img).Error; err != nil {
+			return err
+		}
+		if img.IsPrimary {
+			return promotePrimaryImage(tx, img.ProductID)
+		}
+		return nil
+	})
+	if err != nil {
 		return err
 	}
-
+	deleteStoredFiles(keys)
 	return nil
 }
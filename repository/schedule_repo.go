@@ -0,0 +1,232 @@
+package repository
+
+import (
+	"bogbon-api/config"
+	"bogbon-api/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNotAService     = errors.New("product is not a service")
+	ErrSlotNotOffered  = errors.New("the service is not offered at this time")
+	ErrSlotUnavailable = errors.New("this time slot is fully booked")
+	ErrSlotInPast      = errors.New("this time slot has already started")
+	ErrInvalidSchedule = errors.New("invalid schedule: times must be HH:MM, start before end, positive slot length and capacity")
+	ErrSlotRequired    = errors.New("a time slot is required for this service")
+)
+
+// Slot is one bookable interval of a service on a given day.
+type Slot struct {
+	StartsAt  time.Time `json:"starts_at"`
+	EndsAt    time.Time `json:"ends_at"`
+	Capacity  int       `json:"capacity"`
+	Available int       `json:"available"`
+}
+
+// SlotError ties a booking problem to the cart item that caused it.
+type SlotError struct {
+	CartItemID uint
+	Err        error
+}
+
+func (e *SlotError) Error() string {
+	return fmt.Sprintf("cart item %d: %v", e.CartItemID, e.Err)
+}
+
+func (e *SlotError) Unwrap() error { return e.Err }
+
+// GetServiceSchedule returns the weekly schedule of a service product.
+func GetServiceSchedule(productID uint) ([]models.ServiceSchedule, error) {
+	var schedule []models.ServiceSchedule
+	err := config.DB.Where("product_id = ?", productID).
+		Order("weekday, start_time").
+		Find(&schedule).Error
+	return schedule, err
+}
+
+// SetServiceSchedule replaces the weekly schedule of a service product.
+func SetServiceSchedule(productID uint, schedule []models.ServiceSchedule) error {
+	for _, s := range schedule {
+		if !validSchedule(s) {
+			return ErrInvalidSchedule
+		}
+	}
+
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		var product models.Product
+		if err := tx.First(&product, productID).Error; err != nil {
+			return err
+		}
+		if product.Type != models.ProductTypeService {
+			return ErrNotAService
+		}
+
+		if err := tx.Where("product_id = ?", productID).Delete(&models.ServiceSchedule{}).Error; err != nil {
+			return err
+		}
+		for i := range schedule {
+			schedule[i].ID = 0
+			schedule[i].ProductID = productID
+		}
+		if len(schedule) == 0 {
+			return nil
+		}
+		return tx.Create(&schedule).Error
+	})
+}
+
+// AvailableSlots lists the slots of a service on the given day with remaining capacity.
+// Slots that have already started are left out.
+func AvailableSlots(productID uint, day time.Time) ([]Slot, error) {
+	day = day.In(config.Location)
+	var schedule []models.ServiceSchedule
+	if err := config.DB.Where("product_id = ? AND weekday = ?", productID, int(day.Weekday())).
+		Order("start_time").Find(&schedule).Error; err != nil {
+		return nil, err
+	}
+
+	dayStart := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, config.Location)
+	booked, err := bookedCounts(config.DB, productID, dayStart, dayStart.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	slots := []Slot{}
+	for _, s := range schedule {
+		for _, start := range slotStarts(s, dayStart) {
+			if !start.After(now) {
+				continue
+			}
+			slots = append(slots, Slot{
+				StartsAt:  start,
+				EndsAt:    start.Add(time.Duration(s.SlotMinutes) * time.Minute),
+				Capacity:  s.Capacity,
+				Available: max(s.Capacity-booked[start.Unix()], 0),
+			})
+		}
+	}
+	return slots, nil
+}
+
+// CheckSlot verifies that a slot exists for the service and still has room
+// for quantity bookings. It does not reserve anything; reservations happen
+// at checkout.
+func CheckSlot(productID uint, start time.Time, quantity int) error {
+	_, err := reserveCheck(config.DB, productID, start, 0, quantity)
+	return err
+}
+
+// reserveCheck validates a booking of quantity places in a slot against the
+// schedule and existing reservations, counting pending extra bookings from
+// the same checkout, and returns the slot's end. Callers inside a checkout
+// must hold the product row lock.
+func reserveCheck(tx *gorm.DB, productID uint, start time.Time, pending, quantity int) (time.Time, error) {
+	start = start.In(config.Location)
+	if !start.After(time.Now()) {
+		return time.Time{}, ErrSlotInPast
+	}
+
+	var schedule []models.ServiceSchedule
+	if err := tx.Where("product_id = ? AND weekday = ?", productID, int(start.Weekday())).
+		Find(&schedule).Error; err != nil {
+		return time.Time{}, err
+	}
+
+	dayStart := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, config.Location)
+	for _, s := range schedule {
+		for _, candidate := range slotStarts(s, dayStart) {
+			if !candidate.Equal(start) {
+				continue
+			}
+			var taken int
+			if err := tx.Model(&models.SlotReservation{}).
+				Select("COALESCE(SUM(quantity), 0)").
+				Where("product_id = ? AND starts_at = ?", productID, start).
+				Scan(&taken).Error; err != nil {
+				return time.Time{}, err
+			}
+			if !slotHasRoom(s.Capacity, taken, pending, quantity) {
+				return time.Time{}, ErrSlotUnavailable
+			}
+			return start.Add(time.Duration(s.SlotMinutes) * time.Minute), nil
+		}
+	}
+	return time.Time{}, ErrSlotNotOffered
+}
+
+// slotHasRoom reports whether quantity more places fit in a slot of capacity
+// that has taken places reserved and pending more booked by the same checkout.
+func slotHasRoom(capacity, taken, pending, quantity int) bool {
+	return taken+pending+quantity <= capacity
+}
+
+// hasSchedule reports whether a service product takes bookings.
+func hasSchedule(tx *gorm.DB, productID uint) (bool, error) {
+	var count int64
+	err := tx.Model(&models.ServiceSchedule{}).Where("product_id = ?", productID).Count(&count).Error
+	return count > 0, err
+}
+
+// releaseSlots frees the slots held by an order.
+func releaseSlots(tx *gorm.DB, orderID uint) error {
+	return tx.Where("order_id = ?", orderID).Delete(&models.SlotReservation{}).Error
+}
+
+// bookedCounts returns the places booked per slot start (unix seconds) in [from, to).
+func bookedCounts(db *gorm.DB, productID uint, from, to time.Time) (map[int64]int, error) {
+	var rows []struct {
+		StartsAt time.Time
+		Count    int
+	}
+	err := db.Model(&models.SlotReservation{}).
+		Select("starts_at, SUM(quantity) AS count").
+		Where("product_id = ? AND starts_at >= ? AND starts_at < ?", productID, from, to).
+		Group("starts_at").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[int64]int, len(rows))
+	for _, r := range rows {
+		counts[r.StartsAt.Unix()] = r.Count
+	}
+	return counts, nil
+}
+
+// slotStarts lays out the slot grid of a schedule entry on the given day.
+func slotStarts(s models.ServiceSchedule, dayStart time.Time) []time.Time {
+	open, okOpen := parseClock(s.StartTime)
+	closing, okClose := parseClock(s.EndTime)
+	if !okOpen || !okClose || s.SlotMinutes <= 0 {
+		return nil
+	}
+	step := time.Duration(s.SlotMinutes) * time.Minute
+
+	var starts []time.Time
+	for t := open; t+step <= closing; t += step {
+		starts = append(starts, dayStart.Add(t))
+	}
+	return starts
+}
+
+func validSchedule(s models.ServiceSchedule) bool {
+	open, okOpen := parseClock(s.StartTime)
+	closing, okClose := parseClock(s.EndTime)
+	return okOpen && okClose && open < closing &&
+		s.Weekday >= 0 && s.Weekday <= 6 &&
+		s.SlotMinutes > 0 && s.Capacity > 0
+}
+
+// parseClock parses "HH:MM" into an offset from midnight.
+func parseClock(v string) (time.Duration, bool) {
+	t, err := time.Parse("15:04", v)
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, true
+}
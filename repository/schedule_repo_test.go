@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"bogbon-api/config"
+	"bogbon-api/models"
+)
+
+func TestSlotStarts(t *testing.T) {
+	day := time.Date(2026, 3, 2, 0, 0, 0, 0, config.Location)
+	at := func(h, m int) time.Time { return day.Add(time.Duration(h)*time.Hour + time.Duration(m)*time.Minute) }
+
+	tests := []struct {
+		name string
+		s    models.ServiceSchedule
+		want []time.Time
+	}{
+		{"hourly", models.ServiceSchedule{StartTime: "09:00", EndTime: "12:00", SlotMinutes: 60},
+			[]time.Time{at(9, 0), at(10, 0), at(11, 0)}},
+		{"last slot must end by closing", models.ServiceSchedule{StartTime: "09:00", EndTime: "11:30", SlotMinutes: 60},
+			[]time.Time{at(9, 0), at(10, 0)}},
+		{"odd minutes", models.ServiceSchedule{StartTime: "08:15", EndTime: "09:45", SlotMinutes: 45},
+			[]time.Time{at(8, 15), at(9, 0)}},
+		{"shorter than one slot", models.ServiceSchedule{StartTime: "09:00", EndTime: "09:30", SlotMinutes: 60}, nil},
+		{"closing before opening", models.ServiceSchedule{StartTime: "18:00", EndTime: "09:00", SlotMinutes: 60}, nil},
+		{"zero slot length", models.ServiceSchedule{StartTime: "09:00", EndTime: "18:00", SlotMinutes: 0}, nil},
+		{"bad clock", models.ServiceSchedule{StartTime: "9am", EndTime: "18:00", SlotMinutes: 60}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := slotStarts(tt.s, day)
+			if len(got) != len(tt.want) {
+				t.Fatalf("slotStarts = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Equal(tt.want[i]) {
+					t.Errorf("slot %d = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseClock(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+		ok   bool
+	}{
+		{"00:00", 0, true},
+		{"09:30", 9*time.Hour + 30*time.Minute, true},
+		{"23:59", 23*time.Hour + 59*time.Minute, true},
+		{"24:00", 0, false},
+		{"9:30", 9*time.Hour + 30*time.Minute, true},
+		{"09:60", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseClock(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseClock(%q) = %v, %v, want %v, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestValidSchedule(t *testing.T) {
+	ok := models.ServiceSchedule{Weekday: 1, StartTime: "09:00", EndTime: "18:00", SlotMinutes: 60, Capacity: 2}
+	if !validSchedule(ok) {
+		t.Errorf("validSchedule(%+v) = false", ok)
+	}
+
+	bad := []func(*models.ServiceSchedule){
+		func(s *models.ServiceSchedule) { s.Weekday = 7 },
+		func(s *models.ServiceSchedule) { s.Weekday = -1 },
+		func(s *models.ServiceSchedule) { s.EndTime = s.StartTime },
+		func(s *models.ServiceSchedule) { s.StartTime = "later" },
+		func(s *models.ServiceSchedule) { s.SlotMinutes = 0 },
+		func(s *models.ServiceSchedule) { s.Capacity = 0 },
+	}
+	for i, mutate := range bad {
+		s := ok
+		mutate(&s)
+		if validSchedule(s) {
+			t.Errorf("case %d: validSchedule(%+v) = true", i, s)
+		}
+	}
+}
+
+func TestSlotHasRoom(t *testing.T) {
+	tests := []struct {
+		capacity, taken, pending, quantity int
+		want                               bool
+	}{
+		{1, 0, 0, 1, true},
+		{1, 1, 0, 1, false},
+		{3, 1, 0, 2, true},
+		{3, 1, 1, 2, false},
+		{3, 0, 2, 1, true},
+		{3, 0, 0, 4, false},
+	}
+	for _, tt := range tests {
+		if got := slotHasRoom(tt.capacity, tt.taken, tt.pending, tt.quantity); got != tt.want {
+			t.Errorf("slotHasRoom(capacity %d, taken %d, pending %d, quantity %d) = %v, want %v",
+				tt.capacity, tt.taken, tt.pending, tt.quantity, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package repository
+
+import (
+	"bogbon-api/config"
+	"bogbon-api/models"
+	"bogbon-api/search"
+	"log"
+	"strings"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+// EnsureSearchIndexes adds the full-text search column and indexes that
+// AutoMigrate can't express. The tsvector is generated by PostgreSQL from the
+// translation's own language, so it never goes stale.
+func EnsureSearchIndexes() error {
+	stmts := []string{
+		`ALTER TABLE product_translations ADD COLUMN IF NOT EXISTS search_vector tsvector
+			GENERATED ALWAYS AS (
+				setweight(to_tsvector(` + tsConfigSQL + `, coalesce(name, '')), 'A') ||
+				setweight(to_tsvector(` + tsConfigSQL + `, coalesce(short_info, '')), 'B') ||
+				setweight(to_tsvector(` + tsConfigSQL + `, coalesce(description, '')), 'C')
+			) STORED`,
+		`CREATE INDEX IF NOT EXISTS idx_product_translations_search ON product_translations USING GIN (search_vector)`,
+		`CREATE INDEX IF NOT EXISTS idx_product_translations_lang ON product_translations (language_code, product_id)`,
+	}
+	for _, stmt := range stmts {
+		if err := config.DB.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+
+	// Trigram indexes speed up substring matching on normalized text. pg_trgm
+	// may need elevated rights to install, so searching still works without it.
+	trigram := []string{
+		`CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+		`CREATE INDEX IF NOT EXISTS idx_product_translations_search_text ON product_translations USING GIN (search_text gin_trgm_ops)`,
+		`CREATE INDEX IF NOT EXISTS idx_category_translations_search_text ON category_translations USING GIN (search_text gin_trgm_ops)`,
+	}
+	for _, stmt := range trigram {
+		if err := config.DB.Exec(stmt).Error; err != nil {
+			log.Println("trigram search index unavailable:", err)
+			break
+		}
+	}
+
+	return backfillSearchText()
+}
+
+// backfillSearchText fills the normalized search text of translations saved
+// before it existed, and refreshes it where Normalize has changed since.
+// Saving runs the models' BeforeSave hooks.
+func backfillSearchText() error {
+	var products []models.ProductTranslation
+	err := config.DB.FindInBatches(&products, 200, func(tx *gorm.DB, batch int) error {
+		for i := range products {
+			t := &products[i]
+			old := t.SearchText
+			if err := t.BeforeSave(tx); err != nil || t.SearchText == old {
+				continue
+			}
+			if err := config.DB.Save(t).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	var categories []models.CategoryTranslation
+	return config.DB.FindInBatches(&categories, 200, func(tx *gorm.DB, batch int) error {
+		for i := range categories {
+			t := &categories[i]
+			old := t.SearchText
+			if err := t.BeforeSave(tx); err != nil || t.SearchText == old {
+				continue
+			}
+			if err := config.DB.Save(t).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
+
+// GetAllSynonyms returns every synonym pair.
+func GetAllSynonyms() ([]models.SearchSynonym, error) {
+	var synonyms []models.SearchSynonym
+	err := config.DB.Order("term, synonym").Find(&synonyms).Error
+	return synonyms, err
+}
+
+// AddSynonyms links a term to each of the given synonyms. Terms are stored
+// normalized, so any spelling of them matches. Existing pairs are kept.
+func AddSynonyms(term string, synonyms []string) ([]models.SearchSynonym, error) {
+	term = search.Normalize(term)
+	var created []models.SearchSynonym
+	for _, s := range synonyms {
+		s = search.Normalize(s)
+		if s == "" || s == term {
+			continue
+		}
+		pair := models.SearchSynonym{Term: term, Synonym: s}
+		if err := config.DB.Where(pair).FirstOrCreate(&pair).Error; err != nil {
+			return nil, err
+		}
+		created = append(created, pair)
+	}
+	return created, nil
+}
+
+// DeleteSynonym removes a synonym pair by ID.
+func DeleteSynonym(id uint) error {
+	return config.DB.Delete(&models.SearchSynonym{}, id).Error
+}
+
+// expandTerm returns the normalized term together with all of its synonyms.
+// Pairs work in both directions.
+func expandTerm(term string) ([]string, error) {
+	var pairs []models.SearchSynonym
+	if err := config.DB.Where("term = ? OR synonym = ?", term, term).Find(&pairs).Error; err != nil {
+		return nil, err
+	}
+	terms := []string{term}
+	for _, p := range pairs {
+		if p.Term == term {
+			terms = append(terms, p.Synonym)
+		} else {
+			terms = append(terms, p.Term)
+		}
+	}
+	return terms, nil
+}
+
+// textMatch builds a condition matching normalized free text against a
+// search_text column: every word must appear, either as typed (in any script)
+// or as one of its synonyms. A synonym of the whole phrase also matches.
+// It returns an empty condition when q has no searchable words.
+func textMatch(column, q string) (string, []interface{}, error) {
+	words := search.Words(q)
+	if len(words) == 0 {
+		return "", nil, nil
+	}
+
+	var groups []string
+	var vars []interface{}
+	for _, w := range words {
+		alternatives, err := expandTerm(w)
+		if err != nil {
+			return "", nil, err
+		}
+		var ors []string
+		for _, alt := range alternatives {
+			ors = append(ors, column+" LIKE ?")
+			vars = append(vars, "%"+alt+"%")
+		}
+		groups = append(groups, "("+strings.Join(ors, " OR ")+")")
+	}
+	sql := "(" + strings.Join(groups, " AND ") + ")"
+
+	if len(words) > 1 {
+		phrase, err := expandTerm(strings.Join(words, " "))
+		if err != nil {
+			return "", nil, err
+		}
+		for _, alt := range phrase[1:] {
+			sql += " OR " + column + " LIKE ?"
+			vars = append(vars, "%"+alt+"%")
+		}
+	}
+	return "(" + sql + ")", vars, nil
+}
+
+// tsConfigSQL picks the PostgreSQL text search configuration from a row's language.
+// Uzbek has no stemmer, so it falls back to "simple".
+const tsConfigSQL = `(CASE language_code WHEN 'ru' THEN 'russian'::regconfig WHEN 'en' THEN 'english'::regconfig ELSE 'simple'::regconfig END)`
+
+// searchConfig mirrors tsConfigSQL for query-side parsing.
+func searchConfig(lang string) string {
+	switch lang {
+	case "ru":
+		return "russian"
+	case "en":
+		return "english"
+	default:
+		return "simple"
+	}
+}
+
+// prefixTSQuery turns free text into a tsquery string that matches every word
+// as a prefix, e.g. "mon delic" -> "mon:* & delic:*". Punctuation is dropped so
+// user input can't break the tsquery syntax.
+func prefixTSQuery(q string) string {
+	words := strings.FieldsFunc(q, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, w := range words {
+		words[i] = w + ":*"
+	}
+	return strings.Join(words, " & ")
+}
@@ -0,0 +1,35 @@
+package repository
+
+import "testing"
+
+func TestPrefixTSQuery(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"mon delic", "mon:* & delic:*"},
+		{"  rose  ", "rose:*"},
+		{"rose's & (tulip) | !lily:*", "rose:* & s:* & tulip:* & lily:*"},
+		{"роза 2024", "роза:* & 2024:*"},
+		{"", ""},
+		{"&|!():*", ""},
+	}
+	for _, tt := range tests {
+		if got := prefixTSQuery(tt.in); got != tt.want {
+			t.Errorf("prefixTSQuery(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSearchConfig(t *testing.T) {
+	tests := map[string]string{
+		"ru": "russian",
+		"en": "english",
+		"uz": "simple",
+		"":   "simple",
+	}
+	for lang, want := range tests {
+		if got := searchConfig(lang); got != want {
+			t.Errorf("searchConfig(%q) = %q, want %q", lang, got, want)
+		}
+	}
+}
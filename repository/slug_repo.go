@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"bogbon-api/config"
+	"bogbon-api/models"
+	"bogbon-api/search"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrSlugNotFound = errors.New("slug not found")
+
+// slugTables tells where the live slugs of each kind are stored.
+var slugTables = map[string]struct{ table, owner string }{
+	models.SlugProduct:  {"product_translations", "product_id"},
+	models.SlugCategory: {"category_translations", "category_id"},
+}
+
+// uniqueSlug derives a slug from name that no other product (or category) uses
+// in lang, adding -2, -3, ... on collisions. Names without any usable letters
+// fall back to e.g. "product-12".
+func uniqueSlug(tx *gorm.DB, kind string, ownerID uint, lang, name string) (string, error) {
+	t := slugTables[kind]
+	base := search.Slugify(name)
+	if base == "" {
+		base = fmt.Sprintf("%s-%d", kind, ownerID)
+	}
+
+	slug := base
+	for n := 2; ; n++ {
+		var taken int64
+		if err := tx.Table(t.table).
+			Where("language_code = ? AND slug = ? AND "+t.owner+" <> ?", lang, slug, ownerID).
+			Count(&taken).Error; err != nil {
+			return "", err
+		}
+		if taken == 0 {
+			return slug, nil
+		}
+		slug = fmt.Sprintf("%s-%d", base, n)
+	}
+}
+
+// currentSlugs returns the live slug of a product or category per language.
+func currentSlugs(tx *gorm.DB, kind string, ownerID uint) (map[string]string, error) {
+	t := slugTables[kind]
+	var rows []struct {
+		LanguageCode string
+		Slug         string
+	}
+	if err := tx.Table(t.table).Select("language_code, slug").
+		Where(t.owner+" = ? AND slug <> ''", ownerID).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	slugs := make(map[string]string, len(rows))
+	for _, r := range rows {
+		slugs[r.LanguageCode] = r.Slug
+	}
+	return slugs, nil
+}
+
+// recordSlugChanges keeps old URLs working after a rename: every slug in old
+// that is no longer live becomes a redirect to ownerID, and slugs that are
+// live again stop redirecting.
+func recordSlugChanges(tx *gorm.DB, kind string, ownerID uint, old, current map[string]string) error {
+	for lang, slug := range current {
+		if err := tx.Where("kind = ? AND language_code = ? AND slug = ?", kind, lang, slug).
+			Delete(&models.SlugRedirect{}).Error; err != nil {
+			return err
+		}
+	}
+	for lang, slug := range old {
+		if current[lang] == slug {
+			continue
+		}
+		redirect := models.SlugRedirect{Kind: kind, LanguageCode: lang, Slug: slug, TargetID: ownerID}
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "kind"}, {Name: "language_code"}, {Name: "slug"}},
+			DoUpdates: clause.AssignmentColumns([]string{"target_id", "created_at"}),
+		}).Create(&redirect).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ResolveSlug finds the product or category behind slug in lang. For a former
+// slug, redirected is true and current holds the live slug in lang, or ""
+// when the target no longer has a translation in that language.
+func ResolveSlug(kind, lang, slug string) (id uint, current string, redirected bool, err error) {
+	t, ok := slugTables[kind]
+	if !ok {
+		return 0, "", false, ErrSlugNotFound
+	}
+
+	var ids []uint
+	if err := config.DB.Table(t.table).Where("language_code = ? AND slug = ?", lang, slug).
+		Limit(1).Pluck(t.owner, &ids).Error; err != nil {
+		return 0, "", false, err
+	}
+	if len(ids) > 0 {
+		return ids[0], slug, false, nil
+	}
+
+	var redirect models.SlugRedirect
+	err = config.DB.Where("kind = ? AND language_code = ? AND slug = ?", kind, lang, slug).First(&redirect).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, "", false, ErrSlugNotFound
+	}
+	if err != nil {
+		return 0, "", false, err
+	}
+	slugs, err := currentSlugs(config.DB, kind, redirect.TargetID)
+	if err != nil {
+		return 0, "", false, err
+	}
+	return redirect.TargetID, slugs[lang], true, nil
+}
+
+// EnsureSlugs gives every translation without a slug one, then enforces
+// per-language uniqueness with partial indexes (legacy rows start out empty).
+func EnsureSlugs() error {
+	for kind, t := range slugTables {
+		var rows []struct {
+			ID           uint
+			OwnerID      uint
+			LanguageCode string
+			Name         string
+		}
+		if err := config.DB.Table(t.table).
+			Select("id, " + t.owner + " AS owner_id, language_code, name").
+			Where("slug = ''").Order("id").
+			Scan(&rows).Error; err != nil {
+			return err
+		}
+		for _, r := range rows {
+			slug, err := uniqueSlug(config.DB, kind, r.OwnerID, r.LanguageCode, r.Name)
+			if err != nil {
+				return err
+			}
+			if err := config.DB.Table(t.table).Where("id = ?", r.ID).Update("slug", slug).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := config.DB.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_" + t.table + "_slug ON " + t.table +
+			" (language_code, slug) WHERE slug <> ''").Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
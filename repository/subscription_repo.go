@@ -0,0 +1,397 @@
+package repository
+
+import (
+	"bogbon-api/config"
+	"bogbon-api/models"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrSubscriptionNotFound = errors.New("subscription not found")
+	ErrSubscriptionInactive = errors.New("subscription is not active")
+	ErrSubscriptionState    = errors.New("subscription cannot be changed in its current state")
+	ErrNotAnOccurrence      = errors.New("date is not an upcoming occurrence of this subscription")
+)
+
+// CreateSubscription validates and stores a subscription, aligning its start
+// date to the first matching weekday on or after the requested date. Start
+// dates in the past count from today, so no orders are made for missed dates.
+func CreateSubscription(sub *models.Subscription) error {
+	var product models.Product
+	if err := config.DB.First(&product, sub.ProductID).Error; err != nil {
+		return err
+	}
+	if product.Type != models.ProductTypeService {
+		return ErrNotAService
+	}
+
+	start := dateOf(sub.StartDate)
+	if today := dateOf(time.Now()); start.Before(today) {
+		start = today
+	}
+	sub.StartDate = alignToWeekday(start, time.Weekday(sub.Weekday))
+	sub.NextDate = sub.StartDate
+	sub.Status = models.SubscriptionActive
+	return config.DB.Create(sub).Error
+}
+
+// GetSubscriptionsByCustomer returns the customer's subscriptions.
+func GetSubscriptionsByCustomer(customerID uint) ([]models.Subscription, error) {
+	var subs []models.Subscription
+	err := config.DB.Preload("Product.Translations").Preload("Skips").
+		Where("customer_id = ?", customerID).
+		Order("id").
+		Find(&subs).Error
+	return subs, err
+}
+
+// GetSubscription returns a customer's subscription by ID.
+func GetSubscription(customerID, id uint) (*models.Subscription, error) {
+	var sub models.Subscription
+	err := config.DB.Preload("Product.Translations").Preload("Skips").
+		Where("customer_id = ?", customerID).
+		First(&sub, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrSubscriptionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+// PauseSubscription stops generating orders until the subscription is resumed.
+// Pending orders from today on are cancelled, releasing their stock and slots.
+func PauseSubscription(customerID, id uint) (*models.Subscription, error) {
+	return setSubscriptionStatus(customerID, id, models.SubscriptionActive, models.SubscriptionPaused, nil)
+}
+
+// ResumeSubscription restarts a paused subscription from its first occurrence
+// on or after today. Orders the pause cancelled are generated again.
+func ResumeSubscription(customerID, id uint) (*models.Subscription, error) {
+	return setSubscriptionStatus(customerID, id, models.SubscriptionPaused, models.SubscriptionActive,
+		func(sub *models.Subscription) {
+			sub.NextDate = firstOccurrenceFrom(sub, dateOf(time.Now()))
+		})
+}
+
+// CancelSubscription ends a subscription for good. Pending orders from today
+// on are cancelled; past orders and those already confirmed are kept.
+func CancelSubscription(customerID, id uint) (*models.Subscription, error) {
+	sub, err := GetSubscription(customerID, id)
+	if err != nil {
+		return nil, err
+	}
+	if sub.Status == models.SubscriptionCancelled {
+		return nil, ErrSubscriptionState
+	}
+	return setSubscriptionStatus(customerID, id, sub.Status, models.SubscriptionCancelled, nil)
+}
+
+func setSubscriptionStatus(customerID, id uint, from, to string, mutate func(*models.Subscription)) (*models.Subscription, error) {
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var sub models.Subscription
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("customer_id = ?", customerID).First(&sub, id).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrSubscriptionNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if sub.Status != from {
+			return ErrSubscriptionState
+		}
+		sub.Status = to
+		if mutate != nil {
+			mutate(&sub)
+		}
+		if err := tx.Model(&sub).Select("status", "next_date").Updates(&sub).Error; err != nil {
+			return err
+		}
+		switch to {
+		case models.SubscriptionPaused:
+			return cancelUpcomingOrders(tx, sub.ID, subscriptionPausedNote)
+		case models.SubscriptionCancelled:
+			return cancelUpcomingOrders(tx, sub.ID, "subscription cancelled")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return GetSubscription(customerID, id)
+}
+
+// subscriptionPausedNote marks orders cancelled by a pause in their status
+// history, so that resuming generates their occurrences again.
+const subscriptionPausedNote = "subscription paused"
+
+// cancelUpcomingOrders cancels the pending orders of a subscription scheduled
+// for today or later.
+func cancelUpcomingOrders(tx *gorm.DB, subscriptionID uint, note string) error {
+	var ids []uint
+	err := tx.Model(&models.Order{}).
+		Where("subscription_id = ? AND status = ? AND scheduled_for >= ?",
+			subscriptionID, models.OrderPending, dateOf(time.Now())).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := transitionOrder(tx, id, models.OrderCancelled, nil, note); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SkipSubscriptionDate opts out of one occurrence. If its order was already
+// generated, that order is cancelled instead.
+func SkipSubscriptionDate(customerID, id uint, date time.Time) (*models.Subscription, error) {
+	date = dateOf(date)
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// The generator locks the subscription too, so it cannot create the
+		// order between the cancel and the skip
+		var sub models.Subscription
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("customer_id = ?", customerID).First(&sub, id).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrSubscriptionNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if sub.Status != models.SubscriptionActive {
+			return ErrSubscriptionInactive
+		}
+		if date.Before(dateOf(time.Now())) || !isOccurrence(&sub, date) {
+			return ErrNotAnOccurrence
+		}
+
+		var order models.Order
+		err = tx.Where("subscription_id = ? AND scheduled_for = ? AND status <> ?", sub.ID, date, models.OrderCancelled).
+			Limit(1).Find(&order).Error
+		if err != nil {
+			return err
+		}
+		if order.ID != 0 {
+			if err := transitionOrder(tx, order.ID, models.OrderCancelled, nil, "occurrence skipped by customer"); err != nil {
+				return err
+			}
+		}
+
+		skip := models.SubscriptionSkip{SubscriptionID: sub.ID, Date: date}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&skip).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return GetSubscription(customerID, id)
+}
+
+// GenerateSubscriptionOrders materializes orders for every active subscription
+// occurrence up to now+horizon and returns how many orders were created.
+// Each occurrence is created at most once, so it is safe to run repeatedly.
+func GenerateSubscriptionOrders(horizon time.Duration) (int, error) {
+	until := dateOf(time.Now().Add(horizon))
+
+	var subs []models.Subscription
+	if err := config.DB.Where("status = ? AND next_date <= ?", models.SubscriptionActive, until).
+		Find(&subs).Error; err != nil {
+		return 0, err
+	}
+
+	created := 0
+	for _, sub := range subs {
+		n, err := generateForSubscription(sub.ID, until)
+		created += n
+		if err != nil {
+			log.Printf("subscription %d: %v", sub.ID, err)
+		}
+	}
+	return created, nil
+}
+
+func generateForSubscription(id uint, until time.Time) (int, error) {
+	created := 0
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var sub models.Subscription
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Skips").First(&sub, id).Error
+		if err != nil {
+			return err
+		}
+		if sub.Status != models.SubscriptionActive {
+			return nil
+		}
+
+		skipped := make(map[int64]bool, len(sub.Skips))
+		for _, s := range sub.Skips {
+			skipped[dateOf(s.Date).Unix()] = true
+		}
+
+		for !sub.NextDate.After(until) {
+			date := dateOf(sub.NextDate)
+			if !skipped[date.Unix()] {
+				if err := createSubscriptionOrder(tx, &sub, date); err != nil {
+					return err
+				}
+				created++
+			}
+			sub.NextDate = nextOccurrence(&sub, date)
+		}
+		return tx.Model(&sub).Update("next_date", sub.NextDate).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return created, nil
+}
+
+// createSubscriptionOrder creates the order for one occurrence, booking the
+// preferred slot when the service takes bookings and the slot is free. An
+// occurrence that already has an order is left alone, unless that order was
+// cancelled by pausing the subscription.
+func createSubscriptionOrder(tx *gorm.DB, sub *models.Subscription, date time.Time) error {
+	var existing int64
+	if err := tx.Model(&models.Order{}).
+		Where("subscription_id = ? AND scheduled_for = ?", sub.ID, date).
+		Where(`NOT (status = ? AND EXISTS (SELECT 1 FROM order_status_changes c
+			WHERE c.order_id = orders.id AND c.to_status = ? AND c.note = ?))`,
+			models.OrderCancelled, models.OrderCancelled, subscriptionPausedNote).
+		Count(&existing).Error; err != nil {
+		return err
+	}
+	if existing > 0 {
+		return nil
+	}
+
+	var product models.Product
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Translations").
+		First(&product, sub.ProductID).Error; err != nil {
+		return fmt.Errorf("product %d is no longer available: %w", sub.ProductID, err)
+	}
+
+	var customer models.Customer
+	if err := tx.First(&customer, sub.CustomerID).Error; err != nil {
+		return err
+	}
+
+	item := models.OrderItem{
+		ProductID:   product.ID,
+		Quantity:    sub.Quantity,
+		UnitPrice:   product.Price,
+		LineTotal:   product.Price * sub.Quantity,
+		ProductType: product.Type,
+	}
+	for _, t := range product.Translations {
+		item.Translations = append(item.Translations, models.OrderItemTranslation{
+			LanguageCode: t.LanguageCode,
+			Name:         t.Name,
+		})
+	}
+
+	delivery := sub.DeliveryDetails
+	if offset, ok := parseClock(sub.TimeOfDay); ok {
+		start := date.Add(offset)
+		end, err := reserveCheck(tx, product.ID, start, 0, sub.Quantity)
+		switch {
+		case err == nil:
+			item.SlotStart, item.SlotEnd = &start, &end
+		case errors.Is(err, ErrSlotUnavailable), errors.Is(err, ErrSlotNotOffered), errors.Is(err, ErrSlotInPast):
+			delivery.Notes = fmt.Sprintf("[preferred time %s unavailable: %v] %s", sub.TimeOfDay, err, delivery.Notes)
+		default:
+			return err
+		}
+	}
+
+	customerID := customer.ID
+	subID := sub.ID
+	order := models.Order{
+		SessionID:      customer.SessionID,
+		CustomerID:     &customerID,
+		Status:         models.OrderPending,
+		Subtotal:       item.LineTotal,
+		Total:          item.LineTotal,
+		SubscriptionID: &subID,
+		ScheduledFor:   &date,
+		Items:          []models.OrderItem{item},
+		StatusHistory: []models.OrderStatusChange{
+			{ToStatus: models.OrderPending, Note: "generated from subscription"},
+		},
+		DeliveryDetails: delivery,
+	}
+	if err := tx.Create(&order).Error; err != nil {
+		return err
+	}
+
+	oi := order.Items[0]
+	if oi.SlotStart == nil {
+		return nil
+	}
+	return tx.Create(&models.SlotReservation{
+		ProductID:   oi.ProductID,
+		StartsAt:    *oi.SlotStart,
+		EndsAt:      *oi.SlotEnd,
+		OrderID:     order.ID,
+		OrderItemID: oi.ID,
+		Quantity:    oi.Quantity,
+	}).Error
+}
+
+// nextOccurrence returns the occurrence following date.
+func nextOccurrence(sub *models.Subscription, date time.Time) time.Time {
+	switch sub.Frequency {
+	case models.FrequencyBiweekly:
+		return date.AddDate(0, 0, 14)
+	case models.FrequencyMonthly:
+		// Same ordinal weekday as the start date (e.g. "2nd Tuesday"), capped at the 4th
+		nth := min((sub.StartDate.Day()-1)/7+1, 4)
+		first := time.Date(date.Year(), date.Month()+1, 1, 0, 0, 0, 0, config.Location)
+		return alignToWeekday(first, time.Weekday(sub.Weekday)).AddDate(0, 0, 7*(nth-1))
+	default:
+		return date.AddDate(0, 0, 7)
+	}
+}
+
+// isOccurrence reports whether date is on the subscription's schedule.
+func isOccurrence(sub *models.Subscription, date time.Time) bool {
+	return firstOccurrenceFrom(sub, date).Equal(date)
+}
+
+// firstOccurrenceFrom returns the first occurrence on or after day.
+func firstOccurrenceFrom(sub *models.Subscription, day time.Time) time.Time {
+	d := dateOf(sub.StartDate)
+	for d.Before(day) {
+		d = nextOccurrence(sub, d)
+	}
+	return d
+}
+
+// EnsureSubscriptionOccurrences allows one open order per subscription
+// occurrence. Cancelled orders don't count, so an occurrence whose order a
+// pause cancelled can be generated again after resuming.
+func EnsureSubscriptionOccurrences() error {
+	if err := config.DB.Exec("DROP INDEX IF EXISTS idx_subscription_occurrence").Error; err != nil {
+		return err
+	}
+	return config.DB.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_subscription_occurrence_open
+		ON orders (subscription_id, scheduled_for) WHERE status <> 'cancelled'`).Error
+}
+
+// alignToWeekday returns the first day on or after d that falls on weekday.
+func alignToWeekday(d time.Time, weekday time.Weekday) time.Time {
+	return d.AddDate(0, 0, (int(weekday)-int(d.Weekday())+7)%7)
+}
+
+// dateOf truncates t to midnight in the business time zone.
+func dateOf(t time.Time) time.Time {
+	t = t.In(config.Location)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, config.Location)
+}
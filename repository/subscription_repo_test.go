@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"bogbon-api/config"
+	"bogbon-api/models"
+)
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, config.Location)
+}
+
+func TestNextOccurrence(t *testing.T) {
+	tests := []struct {
+		name      string
+		frequency string
+		start     time.Time
+		from      time.Time
+		want      time.Time
+	}{
+		{"weekly", models.FrequencyWeekly, date(2026, 3, 1), date(2026, 3, 1), date(2026, 3, 8)},
+		{"weekly across month end", models.FrequencyWeekly, date(2026, 3, 1), date(2026, 3, 29), date(2026, 4, 5)},
+		{"unknown frequency is weekly", "", date(2026, 3, 1), date(2026, 3, 1), date(2026, 3, 8)},
+		{"biweekly", models.FrequencyBiweekly, date(2026, 3, 1), date(2026, 3, 1), date(2026, 3, 15)},
+		{"monthly 2nd Tuesday", models.FrequencyMonthly, date(2026, 1, 13), date(2026, 1, 13), date(2026, 2, 10)},
+		{"monthly 5th Thursday capped at 4th", models.FrequencyMonthly, date(2026, 1, 29), date(2026, 1, 29), date(2026, 2, 26)},
+		{"monthly keeps the ordinal", models.FrequencyMonthly, date(2026, 1, 29), date(2026, 2, 26), date(2026, 3, 26)},
+		{"monthly from the last day of a month", models.FrequencyMonthly, date(2026, 1, 31), date(2026, 1, 31), date(2026, 2, 28)},
+		{"monthly into the next year", models.FrequencyMonthly, date(2026, 12, 8), date(2026, 12, 8), date(2027, 1, 12)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := &models.Subscription{Frequency: tt.frequency, StartDate: tt.start, Weekday: int(tt.start.Weekday())}
+			if got := nextOccurrence(sub, tt.from); !got.Equal(tt.want) {
+				t.Errorf("nextOccurrence(%s) = %s, want %s", tt.from.Format(time.DateOnly), got.Format(time.DateOnly), tt.want.Format(time.DateOnly))
+			}
+		})
+	}
+}
+
+func TestIsOccurrence(t *testing.T) {
+	weekly := &models.Subscription{Frequency: models.FrequencyWeekly, StartDate: date(2026, 3, 1)}
+	biweekly := &models.Subscription{Frequency: models.FrequencyBiweekly, StartDate: date(2026, 3, 1)}
+	monthly := &models.Subscription{Frequency: models.FrequencyMonthly, StartDate: date(2026, 1, 29), Weekday: int(time.Thursday)}
+
+	tests := []struct {
+		sub  *models.Subscription
+		day  time.Time
+		want bool
+	}{
+		{weekly, date(2026, 3, 1), true},
+		{weekly, date(2026, 3, 8), true},
+		{weekly, date(2026, 3, 9), false},
+		{weekly, date(2026, 2, 22), false},
+		{biweekly, date(2026, 3, 15), true},
+		{biweekly, date(2026, 3, 8), false},
+		{monthly, date(2026, 2, 26), true},
+		{monthly, date(2026, 2, 19), false},
+		{monthly, date(2026, 3, 26), true},
+	}
+	for _, tt := range tests {
+		if got := isOccurrence(tt.sub, tt.day); got != tt.want {
+			t.Errorf("isOccurrence(%s, %s) = %v, want %v", tt.sub.Frequency, tt.day.Format(time.DateOnly), got, tt.want)
+		}
+	}
+}
+
+func TestFirstOccurrenceFrom(t *testing.T) {
+	sub := &models.Subscription{Frequency: models.FrequencyBiweekly, StartDate: date(2026, 3, 1)}
+	tests := []struct {
+		day, want time.Time
+	}{
+		{date(2026, 2, 1), date(2026, 3, 1)},
+		{date(2026, 3, 1), date(2026, 3, 1)},
+		{date(2026, 3, 2), date(2026, 3, 15)},
+		{date(2026, 3, 15), date(2026, 3, 15)},
+		{date(2026, 3, 16), date(2026, 3, 29)},
+	}
+	for _, tt := range tests {
+		if got := firstOccurrenceFrom(sub, tt.day); !got.Equal(tt.want) {
+			t.Errorf("firstOccurrenceFrom(%s) = %s, want %s", tt.day.Format(time.DateOnly), got.Format(time.DateOnly), tt.want.Format(time.DateOnly))
+		}
+	}
+}
+
+func TestAlignToWeekday(t *testing.T) {
+	// The zero Weekday is Sunday
+	var sub models.Subscription
+	if got := alignToWeekday(date(2026, 3, 4), time.Weekday(sub.Weekday)); !got.Equal(date(2026, 3, 8)) {
+		t.Errorf("alignToWeekday(Wednesday, default) = %s, want Sunday 2026-03-08", got.Format(time.DateOnly))
+	}
+	if got := alignToWeekday(date(2026, 3, 4), time.Wednesday); !got.Equal(date(2026, 3, 4)) {
+		t.Errorf("alignToWeekday(Wednesday, Wednesday) = %s, want the same day", got.Format(time.DateOnly))
+	}
+	if got := alignToWeekday(date(2026, 12, 31), time.Monday); !got.Equal(date(2027, 1, 4)) {
+		t.Errorf("alignToWeekday(2026-12-31, Monday) = %s, want 2027-01-04", got.Format(time.DateOnly))
+	}
+}
@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"bogbon-api/config"
+	"bogbon-api/search"
+)
+
+// RefreshSuggestions rebuilds the in-memory suggestion index from the names
+// of live products and all categories.
+func RefreshSuggestions() error {
+	var entries []search.Entry
+
+	var products []struct {
+		ProductID    uint
+		LanguageCode string
+		Name         string
+	}
+	if err := config.DB.Table("product_translations pt").
+		Select("pt.product_id, pt.language_code, pt.name").
+		Joins("JOIN products p ON p.id = pt.product_id AND p.deleted_at IS NULL").
+		Scan(&products).Error; err != nil {
+		return err
+	}
+	for _, p := range products {
+		entries = append(entries, search.Entry{Kind: search.KindProduct, ID: p.ProductID, Lang: p.LanguageCode, Name: p.Name})
+	}
+
+	var categories []struct {
+		CategoryID   uint
+		LanguageCode string
+		Name         string
+	}
+	if err := config.DB.Table("category_translations").
+		Select("category_id, language_code, name").
+		Scan(&categories).Error; err != nil {
+		return err
+	}
+	for _, c := range categories {
+		entries = append(entries, search.Entry{Kind: search.KindCategory, ID: c.CategoryID, Lang: c.LanguageCode, Name: c.Name})
+	}
+
+	search.Suggestions.Rebuild(entries)
+	return nil
+}
@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"bogbon-api/config"
+	"bogbon-api/models"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrProductNotFound = errors.New("product not found")
+	ErrVariantNotFound = errors.New("variant not found")
+	ErrVariantRequired = errors.New("this product comes in several variants: pick one")
+	ErrVariantMismatch = errors.New("variant does not belong to this product")
+	ErrSKUTaken        = errors.New("SKU is already in use")
+)
+
+// VariantError ties a variant problem found at checkout to its cart item.
+type VariantError struct {
+	CartItemID uint
+	Err        error
+}
+
+func (e *VariantError) Error() string {
+	return fmt.Sprintf("cart item %d: %v", e.CartItemID, e.Err)
+}
+
+func (e *VariantError) Unwrap() error { return e.Err }
+
+// GetProductVariants returns the variants of a product with their options.
+func GetProductVariants(productID uint) ([]models.ProductVariant, error) {
+	var variants []models.ProductVariant
+	err := config.DB.Preload("Options").
+		Where("product_id = ?", productID).
+		Order("id").
+		Find(&variants).Error
+	return variants, err
+}
+
+// CreateVariant adds a variant, with its options, to an existing product.
+func CreateVariant(v *models.ProductVariant) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.First(&models.Product{}, v.ProductID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrProductNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if err := checkSKU(tx, v.SKU, 0); err != nil {
+			return err
+		}
+		return tx.Create(v).Error
+	})
+}
+
+// UpdateVariant saves a variant's SKU, price and stock and replaces its options.
+func UpdateVariant(v *models.ProductVariant) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		var current models.ProductVariant
+		err := tx.First(&current, v.ID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrVariantNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if err := checkSKU(tx, v.SKU, v.ID); err != nil {
+			return err
+		}
+
+		if err := tx.Model(&current).Select("sku", "price", "stock").Updates(v).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("variant_id = ?", v.ID).Delete(&models.VariantOption{}).Error; err != nil {
+			return err
+		}
+		for i := range v.Options {
+			v.Options[i].ID = 0
+			v.Options[i].VariantID = v.ID
+		}
+		if len(v.Options) > 0 {
+			if err := tx.Create(&v.Options).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Preload("Options").First(v, v.ID).Error
+	})
+}
+
+// DeleteVariant removes a variant and the cart lines that point at it.
+// Order items keep their SKU and label snapshot.
+func DeleteVariant(id uint) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("variant_id = ?", id).Delete(&models.CartItem{}).Error; err != nil {
+			return err
+		}
+		res := tx.Delete(&models.ProductVariant{}, id)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrVariantNotFound
+		}
+		return nil
+	})
+}
+
+// CheckVariant verifies a cart choice: products with variants need one of
+// their own variants, products without variants take none.
+func CheckVariant(productID uint, variantID *uint) error {
+	if variantID != nil {
+		var v models.ProductVariant
+		err := config.DB.First(&v, *variantID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrVariantNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if v.ProductID != productID {
+			return ErrVariantMismatch
+		}
+		return nil
+	}
+
+	var count int64
+	if err := config.DB.Model(&models.ProductVariant{}).Where("product_id = ?", productID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrVariantRequired
+	}
+	return nil
+}
+
+func checkSKU(tx *gorm.DB, sku string, exceptID uint) error {
+	var count int64
+	if err := tx.Model(&models.ProductVariant{}).Where("sku = ? AND id <> ?", sku, exceptID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrSKUTaken
+	}
+	return nil
+}
@@ -1,9 +1,13 @@
 package requests
 
+import "time"
+
 // AddToCartInput is the request body for adding an item to cart
 type AddToCartInput struct {
-	ProductID uint `json:"product_id" binding:"required"`
-	Quantity  int  `json:"quantity" binding:"gte=1"`
+	ProductID uint       `json:"product_id" binding:"required"`
+	VariantID *uint      `json:"variant_id"` // required for products with variants
+	Quantity  int        `json:"quantity" binding:"gte=1"`
+	SlotStart *time.Time `json:"slot_start"` // booking slot, services only
 }
 
 // UpdateCartItemInput is the request body for updating cart item quantity
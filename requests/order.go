@@ -0,0 +1,44 @@
+package requests
+
+import "time"
+
+// DeliveryInput holds the contact and address fields shared by checkout and subscriptions
+type DeliveryInput struct {
+	Name          string   `json:"name" binding:"required,max=100"`
+	Phone         string   `json:"phone" binding:"required"`
+	Region        string   `json:"region" binding:"required,max=100"`
+	District      string   `json:"district" binding:"required,max=100"`
+	Street        string   `json:"street" binding:"required,max=255"`
+	Latitude      *float64 `json:"latitude" binding:"omitempty,gte=-90,lte=90"`
+	Longitude     *float64 `json:"longitude" binding:"omitempty,gte=-180,lte=180"`
+	ContactMethod string   `json:"contact_method" binding:"omitempty,oneof=phone sms telegram whatsapp"`
+	Notes         string   `json:"notes" binding:"max=1000"`
+}
+
+// CheckoutInput is the request body for creating an order from the cart
+type CheckoutInput struct {
+	DeliveryInput
+	Slots []SlotChoice `json:"slots" binding:"dive"`
+}
+
+// SlotChoice picks a booking slot for a service item in the cart
+type SlotChoice struct {
+	CartItemID uint      `json:"cart_item_id" binding:"required"`
+	SlotStart  time.Time `json:"slot_start" binding:"required"`
+}
+
+// SubscriptionInput is the request body for subscribing to a recurring service
+type SubscriptionInput struct {
+	DeliveryInput
+	ProductID uint   `json:"product_id" binding:"required"`
+	Quantity  int    `json:"quantity" binding:"gte=1"`
+	Frequency string `json:"frequency" binding:"required,oneof=weekly biweekly monthly"`
+	Weekday   int    `json:"weekday" binding:"gte=0,lte=6"`
+	TimeOfDay string `json:"time_of_day"` // preferred slot start, "HH:MM"
+	StartDate string `json:"start_date"`  // YYYY-MM-DD, defaults to today
+}
+
+// SkipDateInput is the request body for skipping one subscription occurrence
+type SkipDateInput struct {
+	Date string `json:"date" binding:"required"` // YYYY-MM-DD
+}
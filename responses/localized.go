@@ -0,0 +1,225 @@
+// Package responses shapes models for API output. Its localized types replace
+// the Translations arrays of products, categories and order items with the
+// name, description and short info in one language, picked from a fallback
+// chain such as uz -> ru -> en.
+package responses
+
+import "bogbon-api/models"
+
+// Product is a product with its translated fields at the top level.
+type Product struct {
+	models.Product
+	Translations []models.ProductTranslation `json:"Translations,omitempty"`
+	Categories   []Category                  `json:"Categories,omitempty"`
+	Attributes   []Attribute                 `json:"Attributes,omitempty"`
+	Images       []Image
+	Language     string `json:"language"`
+	Name         string `json:"name"`
+	Slug         string `json:"slug"`
+	Description  string `json:"description"`
+	ShortInfo    string `json:"short_info"`
+}
+
+// Image is a product image with its alt text and caption in one language.
+type Image struct {
+	models.ProductImage
+	Translations []models.ImageTranslation `json:"Translations,omitempty"`
+	Language     string                    `json:"language,omitempty"`
+	Alt          string                    `json:"alt"`
+	Caption      string                    `json:"caption"`
+}
+
+// Attribute is one product specification with its labels in one language.
+// Value holds the value codes of an enum attribute, or the number, boolean or
+// text; ValueLabel is the translated enum values, comma-separated.
+type Attribute struct {
+	Code       string      `json:"code"`
+	Label      string      `json:"label"`
+	Type       string      `json:"type"`
+	Unit       string      `json:"unit,omitempty"`
+	Value      interface{} `json:"value"`
+	ValueLabel string      `json:"value_label,omitempty"`
+}
+
+// Category is a category with its translated name at the top level.
+type Category struct {
+	models.Category
+	Translations []models.CategoryTranslation `json:"Translations,omitempty"`
+	Children     []Category                   `json:"Children,omitempty"`
+	Language     string                       `json:"language"`
+	Name         string                       `json:"name"`
+	Slug         string                       `json:"slug"`
+}
+
+// CartItem is a cart item with a localized product.
+type CartItem struct {
+	models.CartItem
+	Product Product
+}
+
+// Order is an order with localized items.
+type Order struct {
+	models.Order
+	Items []OrderItem
+}
+
+// OrderItem is an order item with the product name it was bought under in
+// one language.
+type OrderItem struct {
+	models.OrderItem
+	Translations []models.OrderItemTranslation `json:"Translations,omitempty"`
+	Product      Product
+	Language     string `json:"language"`
+	Name         string `json:"name"`
+}
+
+// NewProduct localizes p for the first language in langs it has a
+// translation for, or its first translation otherwise.
+func NewProduct(p models.Product, langs []string) Product {
+	out := Product{
+		Product:    p,
+		Categories: NewCategories(p.Categories, langs),
+		Attributes: NewAttributes(p.Attributes, langs),
+		Images:     NewImages(p.Images, langs),
+	}
+	if i := pick(len(p.Translations), func(i int) string { return p.Translations[i].LanguageCode }, langs); i >= 0 {
+		t := p.Translations[i]
+		out.Language, out.Name, out.Slug = t.LanguageCode, t.Name, t.Slug
+		out.Description, out.ShortInfo = t.Description, t.ShortInfo
+	}
+	return out
+}
+
+// NewProducts localizes every product in ps.
+func NewProducts(ps []models.Product, langs []string) []Product {
+	out := make([]Product, len(ps))
+	for i, p := range ps {
+		out[i] = NewProduct(p, langs)
+	}
+	return out
+}
+
+// NewImages localizes the alt texts and captions of imgs.
+func NewImages(imgs []models.ProductImage, langs []string) []Image {
+	out := make([]Image, len(imgs))
+	for i, img := range imgs {
+		out[i] = Image{ProductImage: img}
+		if j := pick(len(img.Translations), func(j int) string { return img.Translations[j].LanguageCode }, langs); j >= 0 {
+			t := img.Translations[j]
+			out[i].Language, out[i].Alt, out[i].Caption = t.LanguageCode, t.Alt, t.Caption
+		}
+	}
+	return out
+}
+
+// NewAttributes localizes a product's attribute rows, merging the rows of a
+// multi-valued enum attribute into one entry.
+func NewAttributes(rows []models.ProductAttribute, langs []string) []Attribute {
+	var out []Attribute
+	index := make(map[uint]int)
+	for _, row := range rows {
+		def := row.Attribute
+		i, seen := index[row.AttributeID]
+		if !seen {
+			a := Attribute{Code: def.Code, Type: def.Type, Unit: def.Unit}
+			if j := pick(len(def.Translations), func(j int) string { return def.Translations[j].LanguageCode }, langs); j >= 0 {
+				a.Label = def.Translations[j].Label
+			}
+			switch def.Type {
+			case models.AttributeEnum:
+				a.Value = []string{}
+			case models.AttributeNumber:
+				a.Value = row.Number
+			case models.AttributeBoolean:
+				a.Value = row.Boolean
+			default:
+				a.Value = row.Text
+			}
+			i = len(out)
+			index[row.AttributeID] = i
+			out = append(out, a)
+		}
+
+		if def.Type != models.AttributeEnum || row.Value == nil {
+			continue
+		}
+		v := row.Value
+		out[i].Value = append(out[i].Value.([]string), v.Code)
+		label := v.Code
+		if j := pick(len(v.Translations), func(j int) string { return v.Translations[j].LanguageCode }, langs); j >= 0 {
+			label = v.Translations[j].Label
+		}
+		if out[i].ValueLabel != "" {
+			label = out[i].ValueLabel + ", " + label
+		}
+		out[i].ValueLabel = label
+	}
+	return out
+}
+
+// NewCategory localizes c like NewProduct.
+func NewCategory(c models.Category, langs []string) Category {
+	out := Category{Category: c, Children: NewCategories(c.Children, langs)}
+	if i := pick(len(c.Translations), func(i int) string { return c.Translations[i].LanguageCode }, langs); i >= 0 {
+		t := c.Translations[i]
+		out.Language, out.Name, out.Slug = t.LanguageCode, t.Name, t.Slug
+	}
+	return out
+}
+
+// NewCategories localizes every category in cs.
+func NewCategories(cs []models.Category, langs []string) []Category {
+	out := make([]Category, len(cs))
+	for i, c := range cs {
+		out[i] = NewCategory(c, langs)
+	}
+	return out
+}
+
+// NewCartItems localizes the products of every item in items.
+func NewCartItems(items []models.CartItem, langs []string) []CartItem {
+	out := make([]CartItem, len(items))
+	for i, item := range items {
+		out[i] = CartItem{CartItem: item, Product: NewProduct(item.Product, langs)}
+	}
+	return out
+}
+
+// NewOrder localizes the items of o. Item names come from the snapshot taken
+// at checkout, not from the product's current translations.
+func NewOrder(o models.Order, langs []string) Order {
+	out := Order{Order: o, Items: make([]OrderItem, len(o.Items))}
+	for i, item := range o.Items {
+		oi := OrderItem{OrderItem: item, Product: NewProduct(item.Product, langs)}
+		if j := pick(len(item.Translations), func(j int) string { return item.Translations[j].LanguageCode }, langs); j >= 0 {
+			oi.Language, oi.Name = item.Translations[j].LanguageCode, item.Translations[j].Name
+		}
+		out.Items[i] = oi
+	}
+	return out
+}
+
+// NewOrders localizes every order in os.
+func NewOrders(os []models.Order, langs []string) []Order {
+	out := make([]Order, len(os))
+	for i, o := range os {
+		out[i] = NewOrder(o, langs)
+	}
+	return out
+}
+
+// pick returns the index of the translation to show: the first language of
+// langs that is available, else the first translation, else -1.
+func pick(n int, lang func(int) string, langs []string) int {
+	for _, l := range langs {
+		for i := 0; i < n; i++ {
+			if lang(i) == l {
+				return i
+			}
+		}
+	}
+	if n > 0 {
+		return 0
+	}
+	return -1
+}
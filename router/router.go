@@ -2,26 +2,94 @@ package router
 
 import (
 	"bogbon-api/controllers"
+	"bogbon-api/middlewares"
+	"bogbon-api/models"
+
 	"github.com/gin-gonic/gin"
 )
 
 func Setup(r *gin.Engine) {
 	api := r.Group("/api")
 
+	// Admin auth
+	api.POST("/admin/login", controllers.AdminLogin)
+	admins := api.Group("/admin/users", middlewares.RequireAdmin(models.RoleOwner))
+	{
+		admins.GET("", controllers.ListAdmins)
+		admins.POST("", controllers.CreateAdmin)
+		admins.DELETE("/:id", controllers.DeleteAdmin)
+	}
+
+	// Customer accounts
+	api.POST("/account/register", controllers.Register)
+	api.POST("/account/login", controllers.Login)
+	api.POST("/account/logout", controllers.Logout)
+	api.POST("/account/otp/request", controllers.RequestOTP)
+	api.POST("/account/otp/verify", controllers.VerifyOTP)
+	account := api.Group("/account", middlewares.RequireCustomer())
+	{
+		account.GET("/me", controllers.Me)
+		account.GET("/orders", controllers.MyOrders)
+	}
+
+	// Recurring service subscriptions
+	subscriptions := api.Group("/subscriptions", middlewares.RequireCustomer())
+	{
+		subscriptions.POST("", controllers.CreateSubscription)
+		subscriptions.GET("", controllers.ListSubscriptions)
+		subscriptions.POST("/:id/pause", controllers.PauseSubscription)
+		subscriptions.POST("/:id/resume", controllers.ResumeSubscription)
+		subscriptions.POST("/:id/skip", controllers.SkipSubscriptionDate)
+		subscriptions.DELETE("/:id", controllers.CancelSubscription)
+	}
+
+	// Catalog write routes require a catalog editor
+	catalog := api.Group("", middlewares.RequireAdmin(models.RoleCatalogEditor))
+
 	// Categories
 	api.GET("/categories", controllers.ListCategories)
-	api.POST("/categories", controllers.CreateCategory)
-	api.DELETE("/categories/:id", controllers.DeleteCategory)
-	api.PUT("/categories/:id", controllers.UpdateCategory)
+	api.GET("/categories/tree", controllers.CategoryTree)
+	api.GET("/categories/by-slug/:lang/:slug", controllers.GetCategoryBySlug)
+	catalog.POST("/categories", controllers.CreateCategory)
+	catalog.DELETE("/categories/:id", controllers.DeleteCategory)
+	catalog.PUT("/categories/:id", controllers.UpdateCategory)
 
 	// Products
 	api.GET("/products", controllers.ListProducts)
-	api.POST("/products", controllers.CreateProduct)
+	catalog.POST("/products", controllers.CreateProduct)
 	api.GET("/products/:id", controllers.GetProduct)
-	api.PUT("/products/:id", controllers.UpdateProduct)
-	api.DELETE("/products/:id", controllers.DeleteProduct)
-	api.POST("/products/:id/images", controllers.UploadProductImage) // image upload route
-  api.DELETE("/products/images/:id", controllers.DeleteImage) // image delete route
+	api.GET("/products/by-slug/:lang/:slug", controllers.GetProductBySlug)
+	catalog.PUT("/products/:id", controllers.UpdateProduct)
+	catalog.DELETE("/products/:id", controllers.DeleteProduct)
+	catalog.POST("/products/:id/images", controllers.UploadProductImage) // image upload route
+	catalog.DELETE("/products/images/:id", controllers.DeleteImage)      // image delete route
+	catalog.GET("/images/jobs/:id", controllers.GetImageJob)             // image processing status
+	catalog.PUT("/products/:id/images/order", controllers.ReorderImages)
+	catalog.PUT("/products/images/:id/translations", controllers.SetImageTranslations)
+
+	// Product variants
+	api.GET("/products/:id/variants", controllers.ListVariants)
+	catalog.POST("/products/:id/variants", controllers.CreateVariant)
+	catalog.PUT("/products/variants/:id", controllers.UpdateVariant)
+	catalog.DELETE("/products/variants/:id", controllers.DeleteVariant)
+
+	// Product attributes (specifications)
+	api.GET("/attributes", controllers.ListAttributes)
+	catalog.POST("/attributes", controllers.CreateAttribute)
+	catalog.PUT("/attributes/:id", controllers.UpdateAttribute)
+	catalog.DELETE("/attributes/:id", controllers.DeleteAttribute)
+	catalog.PUT("/products/:id/attributes", controllers.SetProductAttributes)
+
+	// Search
+	api.GET("/search/suggest", controllers.SuggestSearch)
+	catalog.GET("/admin/synonyms", controllers.ListSynonyms)
+	catalog.POST("/admin/synonyms", controllers.CreateSynonyms)
+	catalog.DELETE("/admin/synonyms/:id", controllers.DeleteSynonym)
+
+	// Service booking
+	api.GET("/products/:id/schedule", controllers.GetServiceSchedule)
+	catalog.PUT("/products/:id/schedule", controllers.SetServiceSchedule)
+	api.GET("/products/:id/slots", controllers.ListServiceSlots)
 
 	// Cart
 	cart := api.Group("/cart")
@@ -34,13 +102,35 @@ func Setup(r *gin.Engine) {
 	}
 
 	// Order
+	orderAdmin := middlewares.RequireAdmin(models.RoleOrderManager)
 	order := api.Group("/order")
 	{
-		order.POST("", controllers.CreateOrder)   // Create from cart
-		order.GET("", controllers.GetOrder)       // Latest order
-		order.GET("/all", controllers.ListOrders) // (admin) all orders
-		order.PUT("", controllers.UpdateOrder)    // Mark paid/unpaid
-		order.DELETE("", controllers.DeleteOrder) // Delete all for session
+		order.POST("", controllers.CreateOrder)               // Create from cart
+		order.GET("", controllers.GetOrder)                   // Latest order
+		order.GET("/all", orderAdmin, controllers.ListOrders) // (admin) all orders
+		order.PUT("", controllers.UpdateOrder)                // Cancel latest
+		order.DELETE("", controllers.CancelPendingOrders)     // Cancel pending for session
 	}
-}
 
+	// Order administration
+	orders := api.Group("/admin/orders", orderAdmin)
+	{
+		orders.GET("/:id", controllers.AdminGetOrder)
+		orders.PUT("/:id/status", controllers.AdvanceOrderStatus)
+		orders.POST("/:id/assignments", controllers.AssignOrder)
+	}
+
+	// Crews and dispatch
+	dispatch := api.Group("/admin", orderAdmin)
+	{
+		dispatch.GET("/crews", controllers.ListCrews)
+		dispatch.POST("/crews", controllers.CreateCrew)
+		dispatch.PUT("/crews/:id", controllers.UpdateCrew)
+		dispatch.DELETE("/crews/:id", controllers.DeleteCrew)
+		dispatch.GET("/crews/:id/schedule", controllers.GetCrewSchedule)
+		dispatch.POST("/workers", controllers.CreateWorker)
+		dispatch.PUT("/workers/:id", controllers.UpdateWorker)
+		dispatch.DELETE("/workers/:id", controllers.DeleteWorker)
+		dispatch.DELETE("/assignments/:id", controllers.DeleteAssignment)
+	}
+}
@@ -0,0 +1,83 @@
+// Package search normalizes shopper input and catalog text into one
+// comparable form, so Uzbek Cyrillic, Uzbek Latin (with any apostrophe
+// variant), Russian and accented spellings of the same word line up.
+package search
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// cyrillicToLatin follows the official Uzbek Latin alphabet; Russian-only
+// letters use their common Uzbek spellings. The oʻ/gʻ apostrophe is dropped
+// on both sides (see Normalize), so ў and ғ map to plain o and g.
+var cyrillicToLatin = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "yo",
+	'ж': "j", 'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m",
+	'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u",
+	'ф': "f", 'х': "x", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "sh", 'ъ': "",
+	'ы': "i", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya",
+	'ў': "o", 'қ': "q", 'ғ': "g", 'ҳ': "h",
+}
+
+// yeAfter lists the letters after which е is written "ye" in Uzbek Latin,
+// as it is at the start of a word: the vowels and the hard and soft signs,
+// e.g. "ели" (yeli), "поезд" (poyezd), "объект" (obyekt).
+var yeAfter = "аеёиоуўэюяыъь"
+
+// apostrophes are the characters people type for the Uzbek tutuq belgisi
+// and the oʻ/gʻ modifier.
+var apostrophes = "'`´ʻʼʹ‘’′"
+
+var stripMarks = transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+
+// Normalize folds case and diacritics, transliterates Cyrillic to Latin,
+// drops apostrophes and punctuation, and collapses whitespace.
+// "Oʻrik", "o'rik", "ЎРИК" and "ўрик" all become "orik"; "Ер" becomes "yer".
+func Normalize(s string) string {
+	s = strings.ToLower(s)
+	// Transliterate before folding diacritics: NFD would split й and ё into base + mark
+	var b strings.Builder
+	prev := ' '
+	for _, r := range s {
+		if r == 'е' && (!unicode.IsLetter(prev) || strings.ContainsRune(yeAfter, prev)) {
+			b.WriteString("ye")
+		} else if latin, ok := cyrillicToLatin[r]; ok {
+			b.WriteString(latin)
+		} else {
+			b.WriteRune(r)
+		}
+		prev = r
+	}
+	folded, _, err := transform.String(stripMarks, b.String())
+	if err != nil {
+		folded = b.String()
+	}
+
+	var out strings.Builder
+	space := false
+	for _, r := range folded {
+		switch {
+		case strings.ContainsRune(apostrophes, r):
+			continue
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if space && out.Len() > 0 {
+				out.WriteByte(' ')
+			}
+			space = false
+			out.WriteRune(r)
+		default:
+			space = true
+		}
+	}
+	return out.String()
+}
+
+// Words splits normalized text into its words.
+func Words(s string) []string {
+	return strings.Fields(Normalize(s))
+}
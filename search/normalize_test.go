@@ -0,0 +1,67 @@
+package search
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		// Every spelling of the same Uzbek word
+		{"Oʻrik", "orik"},
+		{"o'rik", "orik"},
+		{"O‘rik", "orik"},
+		{"Oʼrik", "orik"},
+		{"o`rik", "orik"},
+		{"ЎРИК", "orik"},
+		{"ўрик", "orik"},
+
+		// Cyrillic to Latin
+		{"қовун", "qovun"},
+		{"ҳаво", "havo"},
+		{"ғоз", "goz"},
+		{"йўл", "yol"},
+		{"чой", "choy"},
+		{"щётка", "shyotka"},
+		{"цветы", "tsveti"},
+
+		// е is "ye" at the start of a word and after vowels and signs
+		{"Ер", "yer"},
+		{"е", "ye"},
+		{"поезд", "poyezd"},
+		{"объект", "obyekt"},
+		{"кеча", "kecha"},
+		{"эски ер", "eski yer"},
+
+		// Diacritics, punctuation and whitespace
+		{"Café Crème", "cafe creme"},
+		{"50%_off", "50 off"},
+		{"rose-bush, 3 pcs.", "rose bush 3 pcs"},
+		{"  olma\t\n nok  ", "olma nok"},
+		{"", ""},
+		{"%_!", ""},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.in); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestWords(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"G'o'za  paxta", []string{"goza", "paxta"}},
+		{"Ўрик кўчати", []string{"orik", "kochati"}},
+		{" ,. ", nil},
+	}
+	for _, tt := range tests {
+		if got := Words(tt.in); !slices.Equal(got, tt.want) {
+			t.Errorf("Words(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
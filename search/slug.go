@@ -0,0 +1,40 @@
+package search
+
+import "strings"
+
+// maxSlugLen keeps URLs readable; longer names are cut at a word boundary.
+const maxSlugLen = 80
+
+// Slugify turns a name into a URL slug of lowercase ASCII letters, digits and
+// hyphens: "Oʻrik koʻchati" and "Ўрик кўчати" both become "orik-kochati".
+// It returns "" when nothing usable is left.
+func Slugify(name string) string {
+	var words []string
+	for _, w := range Words(name) {
+		var b strings.Builder
+		for _, r := range w {
+			if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+				b.WriteRune(r)
+			}
+		}
+		if b.Len() > 0 {
+			words = append(words, b.String())
+		}
+	}
+
+	slug := ""
+	for _, w := range words {
+		next := w
+		if slug != "" {
+			next = slug + "-" + w
+		}
+		if len(next) > maxSlugLen {
+			if slug == "" {
+				slug = w[:maxSlugLen]
+			}
+			break
+		}
+		slug = next
+	}
+	return slug
+}
@@ -0,0 +1,35 @@
+package search
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSlugify(t *testing.T) {
+	word := "abcdefghij"
+	tests := []struct {
+		name, in, want string
+	}{
+		{"latin", "Oʻrik koʻchati", "orik-kochati"},
+		{"cyrillic", "Ўрик кўчати", "orik-kochati"},
+		{"russian", "Ёлка Еловая", "yolka-yelovaya"},
+		{"punctuation", "Rose — Red! (50%_off)", "rose-red-50-off"},
+		{"accents", "Piñata Café", "pinata-cafe"},
+		{"non-latin letters dropped", "玫瑰 rose", "rose"},
+		{"nothing usable", "!!! 玫瑰", ""},
+		{"empty", "", ""},
+		{"cut at a word boundary", strings.Repeat(word+" ", 8), strings.TrimSuffix(strings.Repeat(word+"-", 7), "-")},
+		{"one overlong word", strings.Repeat("a", 100), strings.Repeat("a", maxSlugLen)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Slugify(tt.in)
+			if got != tt.want {
+				t.Errorf("Slugify(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+			if len(got) > maxSlugLen {
+				t.Errorf("Slugify(%q) is %d bytes long, max %d", tt.in, len(got), maxSlugLen)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package search
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Suggestion kinds
+const (
+	KindProduct  = "product"
+	KindCategory = "category"
+)
+
+// maxPerNode caps how many entries a trie node remembers, which bounds both
+// memory and per-keystroke work for very short prefixes.
+const maxPerNode = 64
+
+// Entry is one completable name in the suggestion index.
+type Entry struct {
+	Kind string `json:"kind"`
+	ID   uint   `json:"id"`
+	Lang string `json:"lang"`
+	Name string `json:"name"`
+
+	normalized string
+}
+
+type trieNode struct {
+	children map[rune]*trieNode
+	entries  []int // indexes into Index.entries
+}
+
+// Index is an in-memory prefix trie over the normalized words of catalog
+// names, one trie per language. Every word of a name is indexed, so "cac"
+// completes "Golden barrel cactus". Queries are safe during a Rebuild.
+type Index struct {
+	mu      sync.RWMutex
+	roots   map[string]*trieNode
+	entries []Entry
+	stale   chan struct{}
+}
+
+// Suggestions is the application's suggestion index.
+var Suggestions = NewIndex()
+
+// NewIndex returns an empty index.
+func NewIndex() *Index {
+	return &Index{roots: map[string]*trieNode{}, stale: make(chan struct{}, 1)}
+}
+
+// Invalidate marks the index as out of date. Calls coalesce until a refresher
+// drains Stale, so bursts of catalog edits trigger a single rebuild.
+func (ix *Index) Invalidate() {
+	select {
+	case ix.stale <- struct{}{}:
+	default:
+	}
+}
+
+// Stale delivers a value whenever the index has been invalidated.
+func (ix *Index) Stale() <-chan struct{} {
+	return ix.stale
+}
+
+// Rebuild replaces the index contents. Shorter names are inserted first so
+// they win the capped node slots.
+func (ix *Index) Rebuild(entries []Entry) {
+	sorted := make([]Entry, len(entries))
+	copy(sorted, entries)
+	for i := range sorted {
+		sorted[i].normalized = Normalize(sorted[i].Name)
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(sorted[i].normalized) < len(sorted[j].normalized)
+	})
+
+	roots := map[string]*trieNode{}
+	for i, e := range sorted {
+		root, ok := roots[e.Lang]
+		if !ok {
+			root = &trieNode{}
+			roots[e.Lang] = root
+		}
+		for _, word := range strings.Fields(e.normalized) {
+			insert(root, word, i)
+		}
+	}
+
+	ix.mu.Lock()
+	ix.roots = roots
+	ix.entries = sorted
+	ix.mu.Unlock()
+}
+
+func insert(root *trieNode, word string, entry int) {
+	n := root
+	for _, r := range word {
+		if n.children == nil {
+			n.children = map[rune]*trieNode{}
+		}
+		child, ok := n.children[r]
+		if !ok {
+			child = &trieNode{}
+			n.children[r] = child
+		}
+		n = child
+		if len(n.entries) < maxPerNode && (len(n.entries) == 0 || n.entries[len(n.entries)-1] != entry) {
+			n.entries = append(n.entries, entry)
+		}
+	}
+}
+
+// Suggest completes the last word of q as a prefix; earlier words must appear
+// in the name. Names starting with the query rank first, then categories,
+// then shorter names.
+func (ix *Index) Suggest(q, lang string, limit int) []Entry {
+	words := strings.Fields(Normalize(q))
+	if len(words) == 0 || limit <= 0 {
+		return []Entry{}
+	}
+	prefix, rest := words[len(words)-1], words[:len(words)-1]
+	full := strings.Join(words, " ")
+
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	n := ix.roots[lang]
+	for _, r := range prefix {
+		if n == nil {
+			return []Entry{}
+		}
+		n = n.children[r]
+	}
+	if n == nil {
+		return []Entry{}
+	}
+
+	type candidate struct {
+		entry Entry
+		rank  int
+	}
+	var found []candidate
+	seen := map[int]bool{}
+	for _, idx := range n.entries {
+		if seen[idx] {
+			continue
+		}
+		seen[idx] = true
+		e := ix.entries[idx]
+		if !containsAll(e.normalized, rest) {
+			continue
+		}
+		rank := 2
+		if strings.HasPrefix(e.normalized, full) {
+			rank = 0
+		}
+		if e.Kind == KindCategory {
+			rank--
+		}
+		found = append(found, candidate{e, rank})
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].rank < found[j].rank
+	})
+	out := make([]Entry, 0, min(limit, len(found)))
+	for _, c := range found {
+		if len(out) == limit {
+			break
+		}
+		out = append(out, c.entry)
+	}
+	return out
+}
+
+func containsAll(text string, words []string) bool {
+	for _, w := range words {
+		if !strings.Contains(text, w) {
+			return false
+		}
+	}
+	return true
+}
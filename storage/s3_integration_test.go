@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestS3Integration runs the S3 driver against a real service such as MinIO.
+// It only runs when S3_TEST_ENDPOINT is set, e.g.
+//
+//	docker run -d -p 9000:9000 minio/minio server /data
+//	mc alias set local http://localhost:9000 minioadmin minioadmin && mc mb local/bogbon-test
+//	S3_TEST_ENDPOINT=http://localhost:9000 S3_TEST_BUCKET=bogbon-test \
+//		S3_TEST_ACCESS_KEY=minioadmin S3_TEST_SECRET_KEY=minioadmin go test ./storage
+//
+// The bucket must exist. Everything the test writes is removed again.
+func TestS3Integration(t *testing.T) {
+	endpoint := os.Getenv("S3_TEST_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("S3_TEST_ENDPOINT not set")
+	}
+	s := &S3{
+		Endpoint:  strings.TrimRight(endpoint, "/"),
+		Region:    os.Getenv("S3_TEST_REGION"),
+		Bucket:    os.Getenv("S3_TEST_BUCKET"),
+		AccessKey: os.Getenv("S3_TEST_ACCESS_KEY"),
+		SecretKey: os.Getenv("S3_TEST_SECRET_KEY"),
+		PathStyle: os.Getenv("S3_TEST_PATH_STYLE") != "false",
+	}
+	if s.Region == "" {
+		s.Region = "us-east-1"
+	}
+
+	prefix := fmt.Sprintf("storage-test-%d/", time.Now().UnixNano())
+	files := map[string][]byte{
+		prefix + "originals/rose_product_1.jpg":  []byte("original"),
+		prefix + "renditions/card/gül lale.webp": []byte("rendition with an escaped key"),
+	}
+	t.Cleanup(func() {
+		for key := range files {
+			s.Delete(key)
+		}
+	})
+
+	for key, data := range files {
+		if err := s.Put(key, bytes.NewReader(data), "application/octet-stream"); err != nil {
+			t.Fatalf("Put %s: %v", key, err)
+		}
+	}
+
+	for key, data := range files {
+		f, err := s.Get(key)
+		if err != nil {
+			t.Fatalf("Get %s: %v", key, err)
+		}
+		got, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			t.Fatalf("Get %s: %v", key, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("Get %s = %q, want %q", key, got, data)
+		}
+	}
+
+	objects, err := s.List(prefix + "renditions/")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	want := prefix + "renditions/card/gül lale.webp"
+	if len(objects) != 1 || objects[0].Key != want || objects[0].Size != int64(len(files[want])) {
+		t.Errorf("List = %+v, want only %s", objects, want)
+	}
+
+	for key := range files {
+		if err := s.Delete(key); err != nil {
+			t.Fatalf("Delete %s: %v", key, err)
+		}
+		if _, err := s.Get(key); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get %s after Delete: %v, want ErrNotFound", key, err)
+		}
+		if err := s.Delete(key); err != nil {
+			t.Errorf("Delete %s again: %v", key, err)
+		}
+	}
+	if objects, err := s.List(prefix); err != nil || len(objects) != 0 {
+		t.Errorf("List after Delete = %+v, %v", objects, err)
+	}
+}
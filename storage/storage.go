@@ -0,0 +1,92 @@
+// Package storage keeps uploaded files such as product images. Files are
+// addressed by keys like "max_uploads/rose_product_<uuid>.webp"; the driver
+// decides where the bytes live and which public URL serves them.
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound   = errors.New("file not found")
+	ErrInvalidKey = errors.New("invalid storage key")
+)
+
+// Storage stores files under keys.
+type Storage interface {
+	// Put stores r under key, replacing any file already there.
+	Put(key string, r io.Reader, contentType string) error
+	// Get opens the file stored under key. Missing files give ErrNotFound.
+	Get(key string) (io.ReadCloser, error)
+	// Delete removes the file under key. Deleting a missing file is not an error.
+	Delete(key string) error
+	// URL is the public address clients download the file from.
+	URL(key string) string
+	// List returns every stored file whose key starts with prefix.
+	List(prefix string) ([]Object, error)
+}
+
+// Object is a stored file as listed by List.
+type Object struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// Files is the storage used by the application; main picks the driver with FromEnv.
+var Files Storage = &Local{Dir: "uploads", BaseURL: "/uploads"}
+
+// FromEnv builds the driver named by STORAGE_DRIVER: "local" (the default)
+// or "s3" for any S3-compatible service such as MinIO.
+//
+// local: files live under STORAGE_DIR (default "uploads") and are served by
+// this API at BASE_URL/uploads.
+//
+// s3: S3_ENDPOINT (e.g. http://localhost:9000), S3_REGION (default
+// us-east-1), S3_BUCKET, S3_ACCESS_KEY, S3_SECRET_KEY, S3_PATH_STYLE
+// (default true, as MinIO expects) and optionally S3_PUBLIC_URL, a CDN or
+// bucket address that serves the objects publicly.
+func FromEnv() (Storage, error) {
+	switch driver := os.Getenv("STORAGE_DRIVER"); driver {
+	case "", "local":
+		dir := os.Getenv("STORAGE_DIR")
+		if dir == "" {
+			dir = "uploads"
+		}
+		return &Local{Dir: dir, BaseURL: strings.TrimRight(os.Getenv("BASE_URL"), "/") + "/uploads"}, nil
+	case "s3":
+		s := &S3{
+			Endpoint:  strings.TrimRight(os.Getenv("S3_ENDPOINT"), "/"),
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+			PublicURL: strings.TrimRight(os.Getenv("S3_PUBLIC_URL"), "/"),
+			PathStyle: os.Getenv("S3_PATH_STYLE") != "false",
+		}
+		if s.Region == "" {
+			s.Region = "us-east-1"
+		}
+		if s.Endpoint == "" || s.Bucket == "" || s.AccessKey == "" || s.SecretKey == "" {
+			return nil, errors.New("S3_ENDPOINT, S3_BUCKET, S3_ACCESS_KEY and S3_SECRET_KEY must be set")
+		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_DRIVER %q", driver)
+	}
+}
+
+// cleanKey rejects keys that are empty, absolute or escape the storage root.
+func cleanKey(key string) (string, error) {
+	clean := path.Clean(key)
+	if key == "" || path.IsAbs(clean) || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	return clean, nil
+}
@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/text/language"
+)
+
+const defaultLanguageChain = "uz,ru,en"
+
+// FallbackLanguages returns the supported languages in fallback order, read
+// from LANG_FALLBACK (e.g. "uz,ru,en").
+func FallbackLanguages() []string {
+	var langs []string
+	for _, l := range strings.Split(os.Getenv("LANG_FALLBACK"), ",") {
+		if l = strings.ToLower(strings.TrimSpace(l)); l != "" {
+			langs = append(langs, l)
+		}
+	}
+	if len(langs) == 0 {
+		return strings.Split(defaultLanguageChain, ",")
+	}
+	return langs
+}
+
+// Languages returns the request's supported languages in order of
+// preference: ?lang= first, then Accept-Language by q value, then the rest of
+// the fallback chain. The result is never empty.
+func Languages(c *gin.Context) []string {
+	chain := FallbackLanguages()
+	supported := make(map[string]bool, len(chain))
+	for _, l := range chain {
+		supported[l] = true
+	}
+
+	var langs []string
+	seen := map[string]bool{}
+	add := func(l string) {
+		if supported[l] && !seen[l] {
+			seen[l] = true
+			langs = append(langs, l)
+		}
+	}
+
+	add(strings.ToLower(c.Query("lang")))
+	if tags, _, err := language.ParseAcceptLanguage(c.GetHeader("Accept-Language")); err == nil {
+		for _, tag := range tags {
+			base, _ := tag.Base()
+			add(base.String())
+		}
+	}
+	for _, l := range chain {
+		add(l)
+	}
+	return langs
+}
+
+// Language returns the request's most preferred supported language.
+func Language(c *gin.Context) string {
+	return Languages(c)[0]
+}
@@ -18,3 +18,26 @@ func GetSessionID(c *gin.Context) string {
 	}
 	return id
 }
+
+// GetCustomerID returns the logged-in customer's ID, if any.
+func GetCustomerID(c *gin.Context) (uint, bool) {
+	id, ok := sessions.Default(c).Get("customer_id").(uint)
+	return id, ok && id != 0
+}
+
+// LoginSession binds the session to a customer and switches it to the
+// customer's stable session ID, so cart and orders follow the account.
+func LoginSession(c *gin.Context, customerID uint, sessionID string) error {
+	sess := sessions.Default(c)
+	sess.Set("customer_id", customerID)
+	sess.Set("session_id", sessionID)
+	return sess.Save()
+}
+
+// LogoutSession forgets the customer and starts a fresh anonymous session.
+func LogoutSession(c *gin.Context) error {
+	sess := sessions.Default(c)
+	sess.Delete("customer_id")
+	sess.Set("session_id", uuid.NewString())
+	return sess.Save()
+}
@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// SMSSender delivers a text message to a phone number.
+type SMSSender interface {
+	Send(phone, message string) error
+}
+
+// SMS is the sender used by the application; swap it for a real gateway in main.
+var SMS SMSSender = LogSMSSender{}
+
+// LogSMSSender "sends" messages by appending them to a file, or to the
+// standard logger when Path is empty. Useful offline and in tests.
+type LogSMSSender struct {
+	Path string
+}
+
+func (s LogSMSSender) Send(phone, message string) error {
+	line := fmt.Sprintf("%s SMS to %s: %s\n", time.Now().Format(time.RFC3339), phone, message)
+	if s.Path == "" {
+		log.Print(line)
+		return nil
+	}
+
+	f, err := os.OpenFile(s.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("unable to open SMS log %s: %w", s.Path, err)
+	}
+	defer f.Close()
+	_, err = f.WriteString(line)
+	return err
+}
+
+// NormalizePhone converts an Uzbek phone number to +998XXXXXXXXX form.
+// It accepts spaces, dashes and parentheses, with or without the country code.
+func NormalizePhone(raw string) (string, bool) {
+	var digits strings.Builder
+	for _, r := range raw {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r == '+' || r == ' ' || r == '-' || r == '(' || r == ')':
+		default:
+			return "", false
+		}
+	}
+
+	d := digits.String()
+	switch {
+	case len(d) == 12 && strings.HasPrefix(d, "998"):
+		return "+" + d, true
+	case len(d) == 9:
+		return "+998" + d, true
+	}
+	return "", false
+}
@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"time"
+)
+
+// TokenClaims is the payload carried by a signed access token.
+type TokenClaims struct {
+	Subject   uint   `json:"sub"`
+	Role      string `json:"role"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// tokenHeader is the fixed JWT header for HS256 tokens.
+var tokenHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// tokenSecret returns the signing key: TOKEN_SECRET, falling back to SESSION_SECRET.
+func tokenSecret() []byte {
+	if s := os.Getenv("TOKEN_SECRET"); s != "" {
+		return []byte(s)
+	}
+	return []byte(os.Getenv("SESSION_SECRET"))
+}
+
+// GenerateToken signs the claims as an HS256 JWT valid for ttl.
+func GenerateToken(subject uint, role string, ttl time.Duration) (string, error) {
+	claims := TokenClaims{
+		Subject:   subject,
+		Role:      role,
+		ExpiresAt: time.Now().Add(ttl).Unix(),
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	unsigned := tokenHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + sign(unsigned), nil
+}
+
+// ParseToken verifies the signature and expiry of a token and returns its claims.
+func ParseToken(token string) (*TokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != tokenHeader {
+		return nil, ErrInvalidToken
+	}
+	expected := sign(parts[0] + "." + parts[1])
+	if !hmac.Equal([]byte(expected), []byte(parts[2])) {
+		return nil, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var claims TokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return nil, ErrInvalidToken
+	}
+	return &claims, nil
+}
+
+func sign(unsigned string) string {
+	mac := hmac.New(sha256.New, tokenSecret())
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,98 @@
+package workers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"bogbon-api/images"
+	"bogbon-api/models"
+	"bogbon-api/repository"
+	"bogbon-api/storage"
+)
+
+// imagePollInterval is how often idle image workers look for due retries
+// and for jobs queued by other instances.
+const imagePollInterval = 10 * time.Second
+
+// imageJobStaleAfter is how long a claimed job may run before it is
+// considered abandoned and requeued.
+const imageJobStaleAfter = 10 * time.Minute
+
+// RunImageWorkers starts n workers that generate the renditions of uploaded
+// images, at most n at a time. It returns immediately.
+func RunImageWorkers(n int) {
+	if requeued, err := repository.RequeueImageJobs(imageJobStaleAfter); err != nil {
+		log.Println("image workers:", err)
+	} else if requeued > 0 {
+		log.Printf("image workers: requeued %d abandoned job(s)", requeued)
+	}
+
+	for i := 0; i < n; i++ {
+		go runImageWorker()
+	}
+	go func() {
+		for range time.Tick(time.Minute) {
+			if _, err := repository.RequeueImageJobs(imageJobStaleAfter); err != nil {
+				log.Println("image workers:", err)
+			}
+		}
+	}()
+}
+
+func runImageWorker() {
+	for {
+		job, err := repository.ClaimImageJob()
+		if err != nil {
+			log.Println("image worker:", err)
+			time.Sleep(imagePollInterval)
+			continue
+		}
+		if job == nil {
+			select {
+			case <-images.Queued():
+			case <-time.After(imagePollInterval):
+			}
+			continue
+		}
+
+		// More jobs may be waiting: let an idle worker look too
+		images.Notify()
+		processImageJob(job)
+	}
+}
+
+func processImageJob(job *models.ImageJob) {
+	// A panic in a decoder or encoder fails the job instead of the API
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("image worker: job %d attempt %d panicked: %v", job.ID, job.Attempts, r)
+			if err := repository.FailImageJob(job, fmt.Errorf("processing panicked: %v", r), true); err != nil {
+				log.Println("image worker:", err)
+			}
+		}
+	}()
+
+	renditions, err := images.StoreRenditions(job.SourceKey)
+	if err == nil {
+		err = repository.CompleteImageJob(job, renditions)
+	}
+	if err == nil {
+		return
+	}
+	if errors.Is(err, repository.ErrImageJobLost) {
+		log.Printf("image worker: job %d attempt %d: %v", job.ID, job.Attempts, err)
+		return
+	}
+
+	// Broken files and deleted products or images will not get better with retries
+	retry := !errors.Is(err, images.ErrInvalidImage) &&
+		!errors.Is(err, storage.ErrNotFound) &&
+		!errors.Is(err, repository.ErrProductNotFound) &&
+		!errors.Is(err, repository.ErrImageNotFound)
+	log.Printf("image worker: job %d attempt %d: %v", job.ID, job.Attempts, err)
+	if err := repository.FailImageJob(job, err, retry); err != nil {
+		log.Println("image worker:", err)
+	}
+}
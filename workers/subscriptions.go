@@ -0,0 +1,22 @@
+package workers
+
+import (
+	"log"
+	"time"
+
+	"bogbon-api/repository"
+)
+
+// RunSubscriptionGenerator materializes upcoming subscription orders every
+// interval, looking horizon ahead. It runs once immediately and never returns.
+func RunSubscriptionGenerator(interval, horizon time.Duration) {
+	for {
+		created, err := repository.GenerateSubscriptionOrders(horizon)
+		if err != nil {
+			log.Println("subscription generator:", err)
+		} else if created > 0 {
+			log.Printf("subscription generator: created %d order(s)", created)
+		}
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,18 @@
+package workers
+
+import (
+	"log"
+
+	"bogbon-api/repository"
+	"bogbon-api/search"
+)
+
+// RunSuggestionRefresher rebuilds the suggestion index whenever the catalog
+// invalidates it. It never returns.
+func RunSuggestionRefresher() {
+	for range search.Suggestions.Stale() {
+		if err := repository.RefreshSuggestions(); err != nil {
+			log.Println("suggestion refresher:", err)
+		}
+	}
+}